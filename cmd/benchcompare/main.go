@@ -0,0 +1,232 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+/*
+Command benchcompare compares two sets of "go test -bench" output
+(e.g. a stored baseline and a new run), and prints per-benchmark deltas.
+
+Benchmarks are aligned by name and configuration (build tags, as printed by bench.sh).
+Run the benchmarks with -count (e.g. -count=6) so that each side has multiple samples;
+the significance of each delta is determined using a Mann-Whitney U-test.
+
+It exits with status 1 if any benchmark matching -codec regressed significantly
+past -threshold percent, for the -metric unit. This allows it gate upgrades
+of github.com/ugorji/go/codec.
+
+Usage:
+
+	benchcompare [flags] old.txt new.txt
+
+Example:
+
+	cd codec
+	go test -tags "alltests x" -bench CodecXSuite -benchmem -count 6 -run XXX > old.txt
+	# ... upgrade github.com/ugorji/go/codec ...
+	go test -tags "alltests x" -bench CodecXSuite -benchmem -count 6 -run XXX > new.txt
+	go run ../cmd/benchcompare -codec '__(Msgpack|Binc|Simple|Cbor|Json)_' -threshold 5 old.txt new.txt
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ugorji/go-codec-bench/internal/benchfmt"
+)
+
+type options struct {
+	alpha     float64
+	threshold float64
+	metric    string
+	units     []string
+	codec     *regexp.Regexp
+}
+
+// delta is the comparison of a single benchmark (and unit) across old and new.
+type delta struct {
+	key      string
+	unit     string
+	old, new []float64
+	pct      float64 // percent change of the mean, new vs old
+	p        float64
+}
+
+func (x *delta) significant(alpha float64) bool { return x.p < alpha }
+
+// regressed returns true if the change is significant and worse than threshold percent.
+func (x *delta) regressed(o *options) bool {
+	if !x.significant(o.alpha) {
+		return false
+	}
+	if benchfmt.HigherIsBetter(x.unit) {
+		return -x.pct > o.threshold
+	}
+	return x.pct > o.threshold
+}
+
+func main() {
+	var o options
+	var units, codec string
+	flag.Float64Var(&o.alpha, "alpha", 0.05, "significance level: deltas with p >= alpha are considered noise")
+	flag.Float64Var(&o.threshold, "threshold", 5, "percent change past which a significant delta is a regression")
+	flag.StringVar(&o.metric, "metric", "ns/op", "unit used to determine regressions")
+	flag.StringVar(&units, "units", "ns/op,B/op,allocs/op", "comma-separated units to print (empty means all)")
+	flag.StringVar(&codec, "codec", "", "regexp of benchmark names which gate (exit non-zero) on regression")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: benchcompare [flags] old.txt new.txt\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if units != "" {
+		o.units = strings.Split(units, ",")
+	}
+	if codec != "" {
+		var err error
+		if o.codec, err = regexp.Compile(codec); err != nil {
+			fatalf("invalid -codec: %v", err)
+		}
+	}
+	oldRes, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	newRes, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if regressions := run(os.Stdout, &o, oldRes, newRes); regressions > 0 {
+		os.Exit(1)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "benchcompare: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+func parseFile(name string) (v []benchfmt.Result, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if v, err = benchfmt.Parse(f); err == nil && len(v) == 0 {
+		err = fmt.Errorf("%s: no benchmark results found", name)
+	}
+	return
+}
+
+// compare aligns old and new results by key, and returns the deltas for each unit.
+// Benchmarks present in only one of old or new are skipped.
+func compare(o *options, oldRes, newRes []benchfmt.Result) (v []delta) {
+	units := o.units
+	if len(units) == 0 {
+		units = benchfmt.Units(append(oldRes[:len(oldRes):len(oldRes)], newRes...))
+	}
+	oldGroups := make(map[string]*benchfmt.Group)
+	for _, g := range benchfmt.GroupByKey(oldRes) {
+		oldGroups[g.Key] = g
+	}
+	for _, unit := range units {
+		for _, ng := range benchfmt.GroupByKey(newRes) {
+			og := oldGroups[ng.Key]
+			if og == nil {
+				continue
+			}
+			d := delta{key: ng.Key, unit: unit, old: og.Samples(unit), new: ng.Samples(unit)}
+			if len(d.old) == 0 || len(d.new) == 0 {
+				continue
+			}
+			om, nm := benchfmt.Mean(d.old), benchfmt.Mean(d.new)
+			switch {
+			case om != 0:
+				d.pct = (nm - om) / om * 100
+			case nm != 0:
+				d.pct = math.Inf(1)
+			}
+			d.p = benchfmt.MannWhitneyU(d.old, d.new)
+			v = append(v, d)
+		}
+	}
+	return
+}
+
+// run prints the comparison, and returns the number of gated regressions.
+func run(w io.Writer, o *options, oldRes, newRes []benchfmt.Result) (regressions int) {
+	deltas := compare(o, oldRes, newRes)
+	var lastUnit string
+	var tw *tabwriter.Writer
+	var fewSamples bool
+	for i := range deltas {
+		d := &deltas[i]
+		if d.unit != lastUnit {
+			if tw != nil {
+				tw.Flush()
+				fmt.Fprintln(w)
+			}
+			lastUnit = d.unit
+			tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintf(tw, "name\told %s\tnew %s\tdelta\t\n", d.unit, d.unit)
+		}
+		var change string
+		if d.significant(o.alpha) {
+			change = fmt.Sprintf("%+.2f%%", d.pct)
+		} else {
+			change = "~"
+		}
+		if len(d.old) < 4 || len(d.new) < 4 {
+			fewSamples = true
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s (p=%.3f n=%d+%d)\t\n", d.key,
+			formatSamples(d.old), formatSamples(d.new), change, d.p, len(d.old), len(d.new))
+	}
+	if tw != nil {
+		tw.Flush()
+	}
+	if len(deltas) == 0 {
+		fmt.Fprintln(w, "no matching benchmarks found in both old and new")
+	}
+	if fewSamples {
+		fmt.Fprintln(w, "\nnote: some benchmarks have fewer than 4 samples; use -count >= 4 for significant results")
+	}
+	if o.codec == nil {
+		return
+	}
+	for i := range deltas {
+		d := &deltas[i]
+		if d.unit == o.metric && o.codec.MatchString(d.key) && d.regressed(o) {
+			if regressions == 0 {
+				fmt.Fprintln(w)
+			}
+			regressions++
+			fmt.Fprintf(w, "REGRESSION: %s: %s %+.2f%% (threshold: %.2f%%, p=%.3f)\n",
+				d.key, d.unit, d.pct, o.threshold, d.p)
+		}
+	}
+	return
+}
+
+func formatSamples(v []float64) string {
+	m := benchfmt.Mean(v)
+	if len(v) < 2 || m == 0 {
+		return formatValue(m)
+	}
+	return fmt.Sprintf("%s ±%.0f%%", formatValue(m), benchfmt.StdDev(v)/m*100)
+}
+
+func formatValue(f float64) string {
+	if math.Abs(f) >= 100 || f == math.Trunc(f) {
+		return fmt.Sprintf("%.0f", f)
+	}
+	return fmt.Sprintf("%.3g", f)
+}
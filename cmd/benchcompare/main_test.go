@@ -0,0 +1,139 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ugorji/go-codec-bench/internal/benchfmt"
+)
+
+// testBenchLines returns the output of -count=len(vals) runs of a benchmark reporting a single unit.
+func testBenchLines(name, unit string, vals ...float64) string {
+	var sb strings.Builder
+	for _, v := range vals {
+		fmt.Fprintf(&sb, "%s-8   \t   10000\t  %g %s\n", name, v, unit)
+	}
+	return sb.String()
+}
+
+func TestCompare(t *testing.T) {
+	const (
+		msgpack = "Benchmark__Msgpack____Encode"
+		stdjson = "Benchmark__Std_Json___Encode"
+	)
+	codecRe := regexp.MustCompile(`__(Msgpack|Binc|Simple|Cbor|Json)_`)
+	base := []float64{1000, 1010, 990, 1005, 995}
+	scaled := func(f float64) (v []float64) {
+		for _, x := range base {
+			v = append(v, x*f)
+		}
+		return
+	}
+	for _, tc := range []struct {
+		name     string
+		old, new string
+		metric   string
+		nocodec  bool     // run without -codec
+		want     []string // keys of the gated regressions
+	}{
+		{
+			name: "codec regression past threshold",
+			old:  testBenchLines(msgpack, "ns/op", base...),
+			new:  testBenchLines(msgpack, "ns/op", scaled(1.10)...),
+			want: []string{msgpack + "-8"},
+		},
+		{
+			name: "codec regression within threshold",
+			old:  testBenchLines(msgpack, "ns/op", base...),
+			new:  testBenchLines(msgpack, "ns/op", scaled(1.03)...),
+		},
+		{
+			name: "codec improvement",
+			old:  testBenchLines(msgpack, "ns/op", base...),
+			new:  testBenchLines(msgpack, "ns/op", scaled(0.80)...),
+		},
+		{
+			name: "regression not significant",
+			old:  testBenchLines(msgpack, "ns/op", 1000, 1500, 900, 1400, 950),
+			new:  testBenchLines(msgpack, "ns/op", 1450, 1000, 1550, 980, 1300),
+		},
+		{
+			name: "regression of a library which does not gate",
+			old:  testBenchLines(stdjson, "ns/op", base...),
+			new:  testBenchLines(stdjson, "ns/op", scaled(1.50)...),
+		},
+		{
+			name:    "no -codec, so nothing gates",
+			old:     testBenchLines(msgpack, "ns/op", base...),
+			new:     testBenchLines(msgpack, "ns/op", scaled(1.50)...),
+			nocodec: true,
+		},
+		{
+			name:   "throughput regression (higher is better)",
+			old:    testBenchLines(msgpack, "MB/s", base...),
+			new:    testBenchLines(msgpack, "MB/s", scaled(0.80)...),
+			metric: "MB/s",
+			want:   []string{msgpack + "-8"},
+		},
+		{
+			name: "only the metric unit gates",
+			old:  testBenchLines(msgpack, "B/op", base...),
+			new:  testBenchLines(msgpack, "B/op", scaled(1.50)...),
+		},
+		{
+			name: "benchmarks in only one of old and new are skipped",
+			old:  testBenchLines(msgpack, "ns/op", base...),
+			new:  testBenchLines("Benchmark__Binc_______Encode", "ns/op", scaled(1.50)...),
+		},
+		{
+			name: "configurations are compared separately",
+			old: ">>>> bench TAGS: 'x' SUITE: S\n" + testBenchLines(msgpack, "ns/op", base...) +
+				">>>> bench TAGS: 'x codec.safe' SUITE: S\n" + testBenchLines(msgpack, "ns/op", base...),
+			new: ">>>> bench TAGS: 'x' SUITE: S\n" + testBenchLines(msgpack, "ns/op", base...) +
+				">>>> bench TAGS: 'x codec.safe' SUITE: S\n" + testBenchLines(msgpack, "ns/op", scaled(1.20)...),
+			want: []string{msgpack + "-8 [x codec.safe]"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := options{alpha: 0.05, threshold: 5, metric: "ns/op", codec: codecRe}
+			if tc.metric != "" {
+				o.metric = tc.metric
+			}
+			if tc.nocodec {
+				o.codec = nil
+			}
+			oldRes, err := benchfmt.Parse(strings.NewReader(tc.old))
+			if err != nil {
+				t.Fatal(err)
+			}
+			newRes, err := benchfmt.Parse(strings.NewReader(tc.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range compare(&o, oldRes, newRes) {
+				if d.unit == o.metric && o.codec != nil && o.codec.MatchString(d.key) && d.regressed(&o) {
+					got = append(got, d.key)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("compare: got regressions %q, want %q", got, tc.want)
+			}
+			var buf bytes.Buffer
+			if n := run(&buf, &o, oldRes, newRes); n != len(tc.want) {
+				t.Errorf("run: got %d regressions, want %d\n%s", n, len(tc.want), buf.Bytes())
+			}
+			for _, k := range tc.want {
+				if !strings.Contains(buf.String(), "REGRESSION: "+k+": ") {
+					t.Errorf("run: regression of %s not printed\n%s", k, buf.Bytes())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/ugorji/go-codec-bench/internal/benchfmt"
+)
+
+// curveUnit is a unit shown as a curve, with whether to use a log scale.
+type curveUnit struct {
+	unit string
+	logY bool
+}
+
+// sweepUnits are the units shown for sweeps (see Benchmark__Sweep in codec).
+var sweepUnits = []curveUnit{
+	{"ns/byte", false},
+	{"encoded-bytes", true},
+	{"allocs/op", true},
+}
+
+// parallelUnits are the units shown for parallel benchmarks (see Benchmark__ParallelEncode in codec),
+// where speedup is derived from ns/op, relative to the smallest GOMAXPROCS.
+var parallelUnits = []curveUnit{
+	{"ns/op", false},
+	{"speedup", false},
+}
+
+// curve holds the values of each library at each point, for a kind of curve (Sweep or Parallel),
+// configuration, workload and operation.
+//
+// For a sweep, the points are the grid of depths and string repeat factors e.g. bd0-trs1.
+// For parallel benchmarks, the points are the GOMAXPROCS values.
+type curve struct {
+	kind, config, workload, op string
+	units                      []curveUnit
+	points                     []string                                 // in the order first seen (sorted numerically for Parallel)
+	libs                       []string                                 // in the order first seen
+	values                     map[string]map[string]map[string]float64 // mean value keyed by library, point and unit
+}
+
+func (x *curve) title() string {
+	s := x.kind + " / " + x.workload + " / " + x.op
+	if x.kind == "Parallel" {
+		s += " by GOMAXPROCS"
+	}
+	if x.config != "" {
+		s += " [" + x.config + "]"
+	}
+	return s
+}
+
+func (x *curve) slug() string {
+	return slugify(x.kind + "-" + x.config + "-" + x.workload + "-" + x.op)
+}
+
+// series returns a line per library, with the values of the unit at each point (NaN if missing).
+func (x *curve) series(unit string) (v []line) {
+	for _, lib := range x.libs {
+		l := line{label: lib, values: make([]float64, len(x.points))}
+		for i, p := range x.points {
+			f, ok := x.values[lib][p][unit]
+			if !ok {
+				f = math.NaN()
+			}
+			l.values[i] = f
+		}
+		v = append(v, l)
+	}
+	return
+}
+
+// rows returns the values of the unit as rows of formatted cells, a row per library
+// (after a header row of the points).
+func (x *curve) rows(unit string) (v [][]string) {
+	v = append(v, append([]string{"Library"}, x.points...))
+	for _, l := range x.series(unit) {
+		row := []string{l.label}
+		for _, f := range l.values {
+			if math.IsNaN(f) {
+				row = append(row, "-")
+			} else {
+				row = append(row, formatValue(f))
+			}
+		}
+		v = append(v, row)
+	}
+	return
+}
+
+var (
+	sweepNameRe    = regexp.MustCompile(`^Benchmark__Sweep/([^/]+)/(Encode|Decode)/([^/]+)(?:/([^/]+))?$`)
+	parallelNameRe = regexp.MustCompile(`^Benchmark__Parallel(Encode|Decode)/([^/]+)(?:/([^/]+))?$`)
+)
+
+// splitCurveName splits the name of a sweep or parallel benchmark result into
+// its kind, point, operation, library and workload e.g.
+// Benchmark__Sweep/bd1-trs8/Encode/Json/small or Benchmark__ParallelEncode/Json/small (with -cpu 4).
+func splitCurveName(r *benchfmt.Result) (kind, point, op, lib, workload string, ok bool) {
+	if m := sweepNameRe.FindStringSubmatch(r.Name); m != nil {
+		return "Sweep", m[1], m[2], m[3], m[4], true
+	}
+	if m := parallelNameRe.FindStringSubmatch(r.Name); m != nil {
+		procs := r.Procs
+		if procs == 0 {
+			procs = 1 // go test omits the -N suffix when GOMAXPROCS is 1
+		}
+		return "Parallel", strconv.Itoa(procs), m[1], m[2], m[3], true
+	}
+	return
+}
+
+// buildCurves groups sweep and parallel results into curves, in the order first seen.
+func buildCurves(results []benchfmt.Result) (v []*curve) {
+	type curveKey struct{ kind, config, workload, op string }
+	curves := make(map[curveKey]*curve)
+	for _, g := range benchfmt.GroupByKey(results) {
+		r := &g.Results[0]
+		kind, point, op, lib, workload, ok := splitCurveName(r)
+		if !ok {
+			continue
+		}
+		if workload == "" {
+			workload = defaultWorkload
+		}
+		k := curveKey{kind, r.Config, workload, op}
+		c := curves[k]
+		if c == nil {
+			c = &curve{kind: kind, config: r.Config, workload: workload, op: op, units: sweepUnits,
+				values: make(map[string]map[string]map[string]float64)}
+			if kind == "Parallel" {
+				c.units = parallelUnits
+			}
+			curves[k] = c
+			v = append(v, c)
+		}
+		if !contains(c.points, point) {
+			c.points = append(c.points, point)
+		}
+		if c.values[lib] == nil {
+			c.libs = append(c.libs, lib)
+			c.values[lib] = make(map[string]map[string]float64)
+		}
+		m := make(map[string]float64)
+		for _, u := range benchfmt.Units(g.Results) {
+			m[u] = benchfmt.Mean(g.Samples(u))
+		}
+		c.values[lib][point] = m
+	}
+	for _, c := range v {
+		if c.kind == "Parallel" {
+			c.addSpeedup()
+		}
+	}
+	return
+}
+
+// addSpeedup sorts the points (GOMAXPROCS values) numerically, and sets the speedup
+// of each library at each point, relative to its ns/op at the first point.
+func (x *curve) addSpeedup() {
+	sort.SliceStable(x.points, func(i, j int) bool {
+		pi, _ := strconv.Atoi(x.points[i])
+		pj, _ := strconv.Atoi(x.points[j])
+		return pi < pj
+	})
+	for _, lib := range x.libs {
+		var base float64
+		for _, p := range x.points {
+			m := x.values[lib][p]
+			if m == nil || m["ns/op"] == 0 {
+				continue
+			}
+			if base == 0 {
+				base = m["ns/op"]
+			}
+			m["speedup"] = base / m["ns/op"]
+		}
+	}
+}
+
+func contains(v []string, s string) bool {
+	for _, x := range v {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// line is a single series in a line chart.
+type line struct {
+	label  string
+	values []float64 // a value per x label (NaN if missing)
+}
+
+// svgLineChart writes a self-contained line chart, with a legend.
+// If logY, the y axis uses a log (base 10) scale.
+func svgLineChart(w io.Writer, title, unit string, xs []string, lines []line, logY bool) {
+	const (
+		left    = 64
+		plotW   = 480
+		plotH   = 220
+		top     = 28
+		bottom  = 48
+		legendW = 130
+		rowH    = 14
+	)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, l := range lines {
+		for _, f := range l.values {
+			if math.IsNaN(f) || (logY && f <= 0) {
+				continue
+			}
+			lo, hi = math.Min(lo, f), math.Max(hi, f)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		lo, hi = 0, 1
+	}
+	if !logY {
+		lo = 0
+	}
+	scale := func(f float64) float64 { return f }
+	if logY {
+		scale = math.Log10
+	}
+	slo, shi := scale(lo), scale(hi)
+	if shi <= slo {
+		shi = slo + 1
+	}
+	y := func(f float64) float64 { return top + plotH - (scale(f)-slo)/(shi-slo)*plotH }
+	x := func(i int) float64 {
+		if len(xs) < 2 {
+			return left + plotW/2
+		}
+		return left + float64(i)*plotW/float64(len(xs)-1)
+	}
+	width, height := left+plotW+16+legendW, top+plotH+bottom
+	if h := top + rowH*len(lines) + 8; h > height {
+		height = h
+	}
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	scaleNote := ""
+	if logY {
+		scaleNote = ", log scale"
+	}
+	fmt.Fprintf(w, `<text x="4" y="16" font-size="12" font-weight="bold">%s (%s%s)</text>`+"\n",
+		html.EscapeString(title), html.EscapeString(unit), scaleNote)
+	fmt.Fprintf(w, `<path d="M%d %d V%d H%d" fill="none" stroke="#999"/>`+"\n", left, top, top+plotH, left+plotW)
+	for _, f := range []float64{lo, hi} {
+		fmt.Fprintf(w, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", left-4, y(f)+4, formatValue(f))
+	}
+	for i, s := range xs {
+		fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="end" transform="rotate(-35 %.1f %d)">%s</text>`+"\n",
+			x(i), top+plotH+14, x(i), top+plotH+14, html.EscapeString(s))
+	}
+	for i, l := range lines {
+		color := lineColor(i)
+		var d []byte
+		for j, f := range l.values {
+			if math.IsNaN(f) || (logY && f <= 0) {
+				continue
+			}
+			cmd := byte('L')
+			if len(d) == 0 {
+				cmd = 'M'
+			}
+			d = append(d, cmd)
+			d = strconv.AppendFloat(d, x(j), 'f', 1, 64)
+			d = append(d, ' ')
+			d = strconv.AppendFloat(d, y(f), 'f', 1, 64)
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%.1f" r="2" fill="%s"/>`+"\n", x(j), y(f), color)
+		}
+		if len(d) > 0 {
+			fmt.Fprintf(w, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", d, color)
+		}
+		ly := top + i*rowH
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="10" height="3" fill="%s"/>`+"\n", left+plotW+16, ly+5, color)
+		fmt.Fprintf(w, `<text x="%d" y="%d">%s</text>`+"\n", left+plotW+30, ly+10, html.EscapeString(l.label))
+	}
+	fmt.Fprintln(w, `</svg>`)
+}
+
+// lineColor returns a distinct color for the i'th line.
+func lineColor(i int) string {
+	return fmt.Sprintf("hsl(%d,65%%,42%%)", (i*137)%360)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+/*
+Command benchreport renders suite results (from "go test -bench" output)
+into a report with ranked tables and self-contained SVG bar charts.
+
+For each configuration (build tags), suite, buffer mode (use-bytes, use-io-1024, use-io-0)
+and operation (Encode, Decode), it shows a leaderboard ranked by ns/op, with speed relative
+to std-json, allocations and encoded size (from the one-pass results if given,
+else as reported by the encode benchmarks).
+
+The reuse strategy benchmarks (Benchmark__ReuseEncode, Benchmark__ReuseDecode) are ranked
+together per operation, with each library named with its strategy e.g. Json/pool.
+The streaming benchmarks (Benchmark__StreamEncode, Benchmark__StreamDecode) are ranked
+together per operation, where each op encodes (or decodes) a whole stream of records.
+The pipe benchmarks (Benchmark__Pipe) are ranked per buffer size e.g. buf-4096.
+The fragmented IO benchmarks (Benchmark__FragmentedEncode, Benchmark__FragmentedDecode)
+are ranked per IO wrapper e.g. byte, chunk512.
+
+For the results of a sweep (Benchmark__Sweep), it shows curves of ns/byte, encoded size
+and allocations across the grid of depths and string repeat factors, per workload and operation.
+For the results of parallel benchmarks (Benchmark__ParallelEncode, Benchmark__ParallelDecode)
+run with -cpu, it shows curves of ns/op and speedup across the GOMAXPROCS values.
+
+Usage:
+
+	benchreport [flags] bench.txt ...
+
+Example:
+
+	cd codec
+	go test -tags "alltests x" -bench CodecXSuite -benchmem -run XXX > bench.txt
+	go test -tags x -run BenchOnePassCheck -bo onepass.json
+	go run ../cmd/benchreport -onepass onepass.json -o report bench.txt
+	go run ../cmd/benchreport -onepass onepass.json -o report -html bench.txt
+	go test -tags x -run XXX -bench __Sweep > sweep.txt
+	go run ../cmd/benchreport -o sweep sweep.txt
+	go test -tags x -run XXX -bench __Parallel -cpu 1,2,4,8 > parallel.txt
+	go run ../cmd/benchreport -o parallel parallel.txt
+
+The Markdown report (report/README.md) references the SVG files written alongside it,
+while the HTML report (report/index.html) embeds them inline.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ugorji/go-codec-bench/internal/benchfmt"
+)
+
+func main() {
+	var outDir, onepass string
+	var html bool
+	flag.StringVar(&outDir, "o", "report", "output directory")
+	flag.StringVar(&onepass, "onepass", "", "one-pass results file in json (see TestBenchOnePassCheck -bo), for encoded sizes")
+	flag.BoolVar(&html, "html", false, "write an HTML report (with inline SVG) instead of Markdown")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: benchreport [flags] bench.txt ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var results []benchfmt.Result
+	for _, name := range flag.Args() {
+		v, err := parseFile(name)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		results = append(results, v...)
+	}
+	var sizes map[string]int
+	if onepass != "" {
+		var err error
+		if sizes, err = readSizes(onepass); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	boards, curves := buildBoards(results, sizes), buildCurves(results)
+	if len(boards) == 0 && len(curves) == 0 {
+		fatalf("no suite, sweep or parallel results found")
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		fatalf("%v", err)
+	}
+	var err error
+	if html {
+		err = writeHTML(filepath.Join(outDir, "index.html"), boards, curves)
+	} else {
+		err = writeMarkdown(outDir, "README.md", boards, curves)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "benchreport: "+format+"\n", args...)
+	os.Exit(2)
+}
+
+func parseFile(name string) (v []benchfmt.Result, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return benchfmt.Parse(f)
+}
+
+// readSizes reads the encoded length of each library from the one-pass results,
+// keyed by normalized library name and workload (see sizeKey).
+func readSizes(name string) (m map[string]int, err error) {
+	bs, err := os.ReadFile(name)
+	if err != nil {
+		return
+	}
+	var v []struct {
+		Name       string `json:"name"`
+		Workload   string `json:"workload"`
+		EncodedLen int    `json:"encodedLen"`
+	}
+	if err = json.Unmarshal(bs, &v); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	m = make(map[string]int, len(v))
+	for _, x := range v {
+		if x.EncodedLen > 0 {
+			m[sizeKey(x.Name, x.Workload)] = x.EncodedLen
+		}
+	}
+	return
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bar is a single bar in a chart.
+type bar struct {
+	label string
+	value float64
+	note  string // annotation written after the value e.g. 2.5x
+}
+
+// svgBarChart writes a self-contained horizontal bar chart.
+func svgBarChart(w io.Writer, title, unit string, bars []bar) {
+	const (
+		labelW = 110
+		barW   = 360
+		noteW  = 170
+		rowH   = 20
+		top    = 28
+	)
+	var max float64
+	for _, b := range bars {
+		max = math.Max(max, b.value)
+	}
+	width, height := labelW+barW+noteW, top+rowH*len(bars)+8
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(w, `<text x="4" y="16" font-weight="bold">%s (%s)</text>`+"\n", html.EscapeString(title), html.EscapeString(unit))
+	for i, b := range bars {
+		y := top + i*rowH
+		var bw float64
+		if max > 0 {
+			bw = b.value / max * barW
+		}
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", labelW-6, y+14, html.EscapeString(b.label))
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`+"\n", labelW, y+3, bw, rowH-6, barColor(i, len(bars)))
+		s := formatValue(b.value)
+		if b.note != "" {
+			s += " (" + b.note + ")"
+		}
+		fmt.Fprintf(w, `<text x="%.1f" y="%d">%s</text>`+"\n", float64(labelW)+bw+4, y+14, html.EscapeString(s))
+	}
+	fmt.Fprintln(w, `</svg>`)
+}
+
+// barColor shades bars from green (best) to red (worst), based on rank.
+func barColor(i, n int) string {
+	f := 0.0
+	if n > 1 {
+		f = float64(i) / float64(n-1)
+	}
+	return fmt.Sprintf("rgb(%d,%d,80)", int(60+f*160), int(170-f*100))
+}
+
+func formatValue(f float64) string {
+	if math.Abs(f) >= 100 || f == math.Trunc(f) {
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(f, 'g', 3, 64)
+}
+
+func formatRatio(f float64) string {
+	if f == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(f, 'f', 2, 64) + "x"
+}
+
+func (x *board) speedBars() (v []bar) {
+	for i := range x.entries {
+		e := &x.entries[i]
+		var note string
+		if s := x.speedup(e); s != 0 {
+			note = formatRatio(s) + " vs std-json"
+		}
+		v = append(v, bar{label: e.lib, value: e.values["ns/op"], note: note})
+	}
+	return
+}
+
+func (x *board) allocBars() (v []bar) {
+	for i := range x.entries {
+		v = append(v, bar{label: x.entries[i].lib, value: x.entries[i].values["allocs/op"]})
+	}
+	sort.SliceStable(v, func(i, j int) bool { return v[i].value < v[j].value })
+	return
+}
+
+// sizeBars returns the encoded size of every library seen across the boards.
+func sizeBars(boards []*board) (v []bar) {
+	seen := make(map[string]bool)
+	var base int
+	for _, b := range boards {
+		for _, e := range b.entries {
+			lib, _, _ := strings.Cut(e.lib, "/") // without the reuse strategy e.g. Json/pool
+			if e.size == 0 || seen[normalizeLib(lib)] {
+				continue
+			}
+			seen[normalizeLib(lib)] = true
+			if normalizeLib(lib) == baselineLib {
+				base = e.size
+			}
+			v = append(v, bar{label: lib, value: float64(e.size)})
+		}
+	}
+	sort.SliceStable(v, func(i, j int) bool { return v[i].value < v[j].value })
+	if base != 0 {
+		for i := range v {
+			v[i].note = formatRatio(v[i].value/float64(base)) + " vs std-json"
+		}
+	}
+	return
+}
+
+func (x *board) slug() string {
+	return slugify(x.config + "-" + x.suite + "-" + x.mode + "-" + x.op)
+}
+
+// slugify returns s in lower case, with runs of other than letters and digits replaced by '-'.
+func slugify(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "-") {
+			sb.WriteByte('-')
+		}
+	}
+	return strings.Trim(sb.String(), "-")
+}
+
+// rows returns the leaderboard as rows of formatted cells (see tableHeader).
+func (x *board) rows() (v [][]string) {
+	for i := range x.entries {
+		e := &x.entries[i]
+		size, relSize := "-", "-"
+		if e.size != 0 {
+			size, relSize = strconv.Itoa(e.size), formatRatio(x.relSize(e))
+		}
+		v = append(v, []string{
+			strconv.Itoa(i + 1), e.lib,
+			formatValue(e.values["ns/op"]), formatRatio(x.speedup(e)),
+			optValue(e.values, "B/op"), optValue(e.values, "allocs/op"),
+			size, relSize,
+		})
+	}
+	return
+}
+
+var tableHeader = []string{"Rank", "Library", "ns/op", "Speed vs std-json", "B/op", "allocs/op", "Size (bytes)", "Size vs std-json"}
+
+func optValue(m map[string]float64, unit string) string {
+	if v, ok := m[unit]; ok {
+		return formatValue(v)
+	}
+	return "-"
+}
+
+func writeMarkdown(dir, name string, boards []*board, curves []*curve) (err error) {
+	var buf bytes.Buffer
+	buf.WriteString("# Benchmark Report\n\n")
+	buf.WriteString("Libraries are ranked by ns/op. Speed is relative to std-json (higher is faster); ")
+	buf.WriteString("size is relative to std-json (lower is smaller).\n\n")
+	if bars := sizeBars(boards); len(bars) > 0 {
+		if err = writeSVGFile(filepath.Join(dir, "size.svg"), "Encoded size", "bytes", bars); err != nil {
+			return
+		}
+		buf.WriteString("## Encoded Size\n\n![Encoded size](size.svg)\n\n")
+	}
+	for _, b := range boards {
+		fmt.Fprintf(&buf, "## %s\n\n", b.title())
+		speed, allocs := b.slug()+"-speed.svg", b.slug()+"-allocs.svg"
+		if err = writeSVGFile(filepath.Join(dir, speed), b.title(), "ns/op", b.speedBars()); err != nil {
+			return
+		}
+		if err = writeSVGFile(filepath.Join(dir, allocs), b.title(), "allocs/op", b.allocBars()); err != nil {
+			return
+		}
+		fmt.Fprintf(&buf, "![speed](%s)\n![allocations](%s)\n\n", speed, allocs)
+		buf.WriteString("| " + strings.Join(tableHeader, " | ") + " |\n")
+		buf.WriteString("|---:|---|---:|---:|---:|---:|---:|---:|\n")
+		for _, row := range b.rows() {
+			buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		buf.WriteString("\n")
+	}
+	for _, c := range curves {
+		fmt.Fprintf(&buf, "## %s\n\n", c.title())
+		for _, u := range c.units {
+			svg := c.slug() + "-" + slugify(u.unit) + ".svg"
+			var sb bytes.Buffer
+			svgLineChart(&sb, c.title(), u.unit, c.points, c.series(u.unit), u.logY)
+			if err = os.WriteFile(filepath.Join(dir, svg), sb.Bytes(), 0o644); err != nil {
+				return
+			}
+			fmt.Fprintf(&buf, "![%s](%s)\n", u.unit, svg)
+		}
+		fmt.Fprintf(&buf, "\n%s:\n\n", c.units[0].unit)
+		for i, row := range c.rows(c.units[0].unit) {
+			buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+			if i == 0 {
+				buf.WriteString("|---" + strings.Repeat("|---:", len(row)-1) + "|\n")
+			}
+		}
+		buf.WriteString("\n")
+	}
+	return os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644)
+}
+
+func writeSVGFile(name, title, unit string, bars []bar) error {
+	var buf bytes.Buffer
+	svgBarChart(&buf, title, unit, bars)
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
+
+func writeHTML(name string, boards []*board, curves []*curve) error {
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Benchmark Report</title>\n")
+	buf.WriteString("<style>body{font-family:sans-serif} table{border-collapse:collapse;margin-bottom:2em} " +
+		"td,th{border:1px solid #ccc;padding:2px 8px;text-align:right} td:nth-child(2){text-align:left}</style>\n")
+	buf.WriteString("</head><body>\n<h1>Benchmark Report</h1>\n")
+	buf.WriteString("<p>Libraries are ranked by ns/op. Speed is relative to std-json (higher is faster); " +
+		"size is relative to std-json (lower is smaller).</p>\n")
+	if bars := sizeBars(boards); len(bars) > 0 {
+		buf.WriteString("<h2>Encoded Size</h2>\n")
+		svgBarChart(&buf, "Encoded size", "bytes", bars)
+	}
+	for _, b := range boards {
+		fmt.Fprintf(&buf, "<h2>%s</h2>\n", html.EscapeString(b.title()))
+		svgBarChart(&buf, b.title(), "ns/op", b.speedBars())
+		svgBarChart(&buf, b.title(), "allocs/op", b.allocBars())
+		buf.WriteString("<table>\n<tr>")
+		for _, h := range tableHeader {
+			fmt.Fprintf(&buf, "<th>%s</th>", html.EscapeString(h))
+		}
+		buf.WriteString("</tr>\n")
+		for _, row := range b.rows() {
+			buf.WriteString("<tr>")
+			for _, c := range row {
+				fmt.Fprintf(&buf, "<td>%s</td>", html.EscapeString(c))
+			}
+			buf.WriteString("</tr>\n")
+		}
+		buf.WriteString("</table>\n")
+	}
+	for _, c := range curves {
+		fmt.Fprintf(&buf, "<h2>%s</h2>\n", html.EscapeString(c.title()))
+		for _, u := range c.units {
+			svgLineChart(&buf, c.title(), u.unit, c.points, c.series(u.unit), u.logY)
+		}
+		fmt.Fprintf(&buf, "<p>%s:</p>\n<table>\n", html.EscapeString(c.units[0].unit))
+		for i, row := range c.rows(c.units[0].unit) {
+			buf.WriteString("<tr>")
+			for _, v := range row {
+				if i == 0 {
+					fmt.Fprintf(&buf, "<th>%s</th>", html.EscapeString(v))
+				} else {
+					fmt.Fprintf(&buf, "<td>%s</td>", html.EscapeString(v))
+				}
+			}
+			buf.WriteString("</tr>\n")
+		}
+		buf.WriteString("</table>\n")
+	}
+	buf.WriteString("</body></html>\n")
+	return os.WriteFile(name, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ugorji/go-codec-bench/internal/benchfmt"
+)
+
+// baselineLib is the library which others are compared against.
+const baselineLib = "stdjson"
+
+// defaultWorkload is the workload of benchmarks whose names do not include one.
+const defaultWorkload = "teststruc"
+
+// entry is the (mean) result for a library in a leaderboard.
+type entry struct {
+	lib    string             // library title e.g. Std_Json
+	values map[string]float64 // mean value keyed by unit e.g. ns/op
+	size   int                // encoded size in bytes, from the one-pass results or encode benchmarks (0 if unknown)
+}
+
+// board is a ranked leaderboard for a configuration, suite, buffer mode, workload and operation.
+type board struct {
+	config, suite, mode, workload, op string
+	entries                           []entry // sorted by ns/op
+	baseline                          *entry  // std-json entry, if present
+}
+
+func (x *board) title() string {
+	s := x.suite
+	if x.mode != "" {
+		s += " / " + x.mode
+	}
+	if x.workload != "" {
+		s += " / " + x.workload
+	}
+	s += " / " + x.op
+	if x.config != "" {
+		s += " [" + x.config + "]"
+	}
+	return s
+}
+
+// speedup returns how many times faster e is than std-json (0 if unknown).
+func (x *board) speedup(e *entry) float64 {
+	if x.baseline == nil || e.values["ns/op"] == 0 {
+		return 0
+	}
+	return x.baseline.values["ns/op"] / e.values["ns/op"]
+}
+
+// relSize returns the size of e relative to std-json (0 if unknown).
+func (x *board) relSize(e *entry) float64 {
+	if x.baseline == nil || x.baseline.size == 0 || e.size == 0 {
+		return 0
+	}
+	return float64(e.size) / float64(x.baseline.size)
+}
+
+var (
+	benchNameRe      = regexp.MustCompile(`^Benchmark__(.+?)_*(Encode|Decode)$`)
+	reuseNameRe      = regexp.MustCompile(`^Benchmark__Reuse(Encode|Decode)/([^/]+)/([^/]+)(?:/([^/]+))?$`)
+	streamNameRe     = regexp.MustCompile(`^Benchmark__Stream(Encode|Decode)/([^/]+)(?:/([^/]+))?$`)
+	pipeNameRe       = regexp.MustCompile(`^Benchmark__Pipe/(buf-\d+)/([^/]+)(?:/([^/]+))?$`)
+	fragmentedNameRe = regexp.MustCompile(`^Benchmark__Fragmented(Encode|Decode)/([^/]+)/([^/]+)(?:/([^/]+))?$`)
+)
+
+// splitName splits a benchmark name into its suite, buffer mode, workload, library and operation.
+//
+// It supports the names of the suites e.g.
+// BenchmarkCodecXSuite/use-bytes......./Benchmark__Std_Json___Encode
+// and the top-level benchmarks e.g. Benchmark__Encode/Std_Json.
+// Either may be followed by a workload (when not the default) e.g. Benchmark__Encode/Std_Json/small.
+//
+// For the reuse strategy benchmarks e.g. Benchmark__ReuseEncode/Json/pool,
+// the suite is Benchmark__Reuse, and the library includes the strategy e.g. Json/pool.
+// For the streaming benchmarks e.g. Benchmark__StreamEncode/Json, the suite is Benchmark__Stream.
+// For the pipe benchmarks e.g. Benchmark__Pipe/buf-4096/Json, the buffer size is the mode,
+// and the operation is Pipe (as each op both encodes and decodes).
+// For the fragmented IO benchmarks e.g. Benchmark__FragmentedDecode/byte/Json, the IO wrapper is the mode.
+func splitName(name string) (suite, mode, workload, lib, op string, ok bool) {
+	if m := reuseNameRe.FindStringSubmatch(name); m != nil {
+		return "Benchmark__Reuse", "", m[4], m[2] + "/" + m[3], m[1], true
+	}
+	if m := streamNameRe.FindStringSubmatch(name); m != nil {
+		return "Benchmark__Stream", "", m[3], m[2], m[1], true
+	}
+	if m := pipeNameRe.FindStringSubmatch(name); m != nil {
+		return "Benchmark__Pipe", m[1], m[3], m[2], "Pipe", true
+	}
+	if m := fragmentedNameRe.FindStringSubmatch(name); m != nil {
+		return "Benchmark__Fragmented", m[2], m[4], m[3], m[1], true
+	}
+	parts := strings.Split(name, "/")
+	if n := len(parts); n > 1 && (benchNameRe.MatchString(parts[n-2]) ||
+		(n == 3 && (parts[0] == "Benchmark__Encode" || parts[0] == "Benchmark__Decode"))) {
+		workload, parts = parts[n-1], parts[:n-1]
+	}
+	last := parts[len(parts)-1]
+	if m := benchNameRe.FindStringSubmatch(last); m != nil {
+		lib, op = m[1], m[2]
+		switch len(parts) {
+		case 1:
+		case 2:
+			suite = parts[0]
+		default:
+			suite, mode = parts[0], strings.TrimRight(parts[1], ".-")
+		}
+	} else if len(parts) == 2 && (parts[0] == "Benchmark__Encode" || parts[0] == "Benchmark__Decode") {
+		suite, lib, op = parts[0], parts[1], strings.TrimPrefix(parts[0], "Benchmark__")
+	} else {
+		return
+	}
+	ok = lib != ""
+	return
+}
+
+// normalizeLib returns a key used to match library names and titles
+// e.g. std-json and Std_Json both become stdjson.
+func normalizeLib(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// sizeKey returns the key for the encoded size of a library for a workload.
+// An empty workload is the default workload (teststruc).
+func sizeKey(lib, workload string) string {
+	if workload == "" {
+		workload = defaultWorkload
+	}
+	return normalizeLib(lib) + "/" + strings.ToLower(workload)
+}
+
+// buildBoards groups results into leaderboards, in the order first seen.
+func buildBoards(results []benchfmt.Result, sizes map[string]int) (v []*board) {
+	type boardKey struct{ config, suite, mode, workload, op string }
+	boards := make(map[boardKey]*board)
+	for _, g := range benchfmt.GroupByKey(results) {
+		r := &g.Results[0]
+		if _, _, _, _, _, ok := splitCurveName(r); ok {
+			continue // shown as curves (see buildCurves)
+		}
+		suite, mode, workload, lib, op, ok := splitName(r.Name)
+		if !ok {
+			continue
+		}
+		k := boardKey{r.Config, suite, mode, workload, op}
+		b := boards[k]
+		if b == nil {
+			b = &board{config: r.Config, suite: suite, mode: mode, workload: workload, op: op}
+			boards[k] = b
+			v = append(v, b)
+		}
+		e := entry{lib: lib, values: make(map[string]float64), size: sizes[sizeKey(lib, workload)]}
+		for _, u := range benchfmt.Units(g.Results) {
+			e.values[u] = benchfmt.Mean(g.Samples(u))
+		}
+		// decode benchmarks may decode what another encoder produced (e.g. codec's for json),
+		// so only the encode benchmarks report the library's own encoded size.
+		if e.size == 0 && op == "Encode" {
+			e.size = int(e.values["encoded-bytes"])
+		}
+		b.entries = append(b.entries, e)
+	}
+	for _, b := range v {
+		sort.SliceStable(b.entries, func(i, j int) bool {
+			return b.entries[i].values["ns/op"] < b.entries[j].values["ns/op"]
+		})
+		for i := range b.entries {
+			if normalizeLib(b.entries[i].lib) == baselineLib {
+				b.baseline = &b.entries[i]
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ugorji/go-codec-bench/internal/benchfmt"
+)
+
+func TestSplitName(t *testing.T) {
+	for _, tc := range []struct {
+		name, suite, mode, workload, lib, op string
+	}{
+		{"BenchmarkCodecXSuite/use-bytes......./Benchmark__Std_Json___Encode", "BenchmarkCodecXSuite", "use-bytes", "", "Std_Json", "Encode"},
+		{"BenchmarkCodecXSuite/use-io-1024-..../Benchmark__GoccyJson__Decode", "BenchmarkCodecXSuite", "use-io-1024", "", "GoccyJson", "Decode"},
+		{"BenchmarkCodecXSuite/use-bytes......./Benchmark__Std_Json___Encode/small", "BenchmarkCodecXSuite", "use-bytes", "small", "Std_Json", "Encode"},
+		{"Benchmark__Encode/JsonIter", "Benchmark__Encode", "", "", "JsonIter", "Encode"},
+		{"Benchmark__Decode/JsonIter/nested", "Benchmark__Decode", "", "nested", "JsonIter", "Decode"},
+		{"Benchmark__ReuseEncode/Json/pool", "Benchmark__Reuse", "", "", "Json/pool", "Encode"},
+		{"Benchmark__ReuseDecode/VMsgpack/reset/small", "Benchmark__Reuse", "", "small", "VMsgpack/reset", "Decode"},
+		{"Benchmark__StreamEncode/Gob", "Benchmark__Stream", "", "", "Gob", "Encode"},
+		{"Benchmark__StreamDecode/Json/nested", "Benchmark__Stream", "", "nested", "Json", "Decode"},
+		{"Benchmark__Pipe/buf-4096/Gob", "Benchmark__Pipe", "buf-4096", "", "Gob", "Pipe"},
+		{"Benchmark__Pipe/buf-0/Json/small", "Benchmark__Pipe", "buf-0", "small", "Json", "Pipe"},
+		{"Benchmark__FragmentedDecode/byte/Fxcbor", "Benchmark__Fragmented", "byte", "", "Fxcbor", "Decode"},
+		{"Benchmark__FragmentedEncode/chunk512-lat20us/Json/small", "Benchmark__Fragmented", "chunk512-lat20us", "small", "Json", "Encode"},
+	} {
+		suite, mode, workload, lib, op, ok := splitName(tc.name)
+		if !ok || suite != tc.suite || mode != tc.mode || workload != tc.workload || lib != tc.lib || op != tc.op {
+			t.Errorf("splitName(%q): got %q %q %q %q %q %v", tc.name, suite, mode, workload, lib, op, ok)
+		}
+	}
+	if _, _, _, _, _, ok := splitName("BenchmarkSomethingElse"); ok {
+		t.Errorf("splitName: expected unknown name to not be ok")
+	}
+}
+
+const testBenchOutput = `
+BenchmarkCodecXSuite/use-bytes......./Benchmark__Json_______Encode-8   100	  200 ns/op	 24 B/op	 1 allocs/op
+BenchmarkCodecXSuite/use-bytes......./Benchmark__Std_Json___Encode-8   100	  400 ns/op	 80 B/op	 5 allocs/op
+BenchmarkCodecXSuite/use-bytes......./Benchmark__Msgpack____Encode-8   100	  100 ns/op	 24 B/op	 1 allocs/op
+BenchmarkCodecXSuite/use-io-0-......./Benchmark__Msgpack____Encode-8   100	  150 ns/op	 24 B/op	 1 allocs/op
+`
+
+func TestBuildBoards(t *testing.T) {
+	results, err := benchfmt.Parse(strings.NewReader(testBenchOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boards := buildBoards(results, map[string]int{"stdjson/teststruc": 1000, "msgpack/teststruc": 800})
+	if len(boards) != 2 {
+		t.Fatalf("expected 2 boards, got %d", len(boards))
+	}
+	b := boards[0]
+	if len(b.entries) != 3 || b.entries[0].lib != "Msgpack" || b.entries[2].lib != "Std_Json" {
+		t.Fatalf("unexpected ranking: %v", b.entries)
+	}
+	if s := b.speedup(&b.entries[0]); s != 4 {
+		t.Fatalf("expected Msgpack to be 4x std-json, got %v", s)
+	}
+	if s := b.relSize(&b.entries[0]); s != 0.8 {
+		t.Fatalf("expected Msgpack size to be 0.8x std-json, got %v", s)
+	}
+	if boards[1].baseline != nil {
+		t.Fatalf("expected no baseline for use-io-0 board")
+	}
+	var buf bytes.Buffer
+	svgBarChart(&buf, b.title(), "ns/op", b.speedBars())
+	if s := buf.String(); !strings.HasPrefix(s, "<svg ") || !strings.Contains(s, "4.00x vs std-json") {
+		t.Fatalf("unexpected svg: %s", s)
+	}
+}
+
+const testSweepOutput = `
+Benchmark__Sweep/bd0-trs1/Encode/Json-8       100	  200 ns/op	 1000 encoded-bytes	 0.2 ns/byte	 24 B/op	 1 allocs/op
+Benchmark__Sweep/bd0-trs1/Encode/Std_Json-8   100	  400 ns/op	 1000 encoded-bytes	 0.4 ns/byte	 80 B/op	 5 allocs/op
+Benchmark__Sweep/bd1-trs1/Encode/Json-8       100	 1000 ns/op	 4000 encoded-bytes	0.25 ns/byte	 24 B/op	 1 allocs/op
+Benchmark__Sweep/bd1-trs1/Encode/Json/small-8 100	   50 ns/op	  100 encoded-bytes	 0.5 ns/byte	  0 B/op	 0 allocs/op
+`
+
+func TestBuildCurves(t *testing.T) {
+	results, err := benchfmt.Parse(strings.NewReader(testSweepOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if boards := buildBoards(results, nil); len(boards) != 0 {
+		t.Fatalf("expected no boards from sweep results, got %d", len(boards))
+	}
+	curves := buildCurves(results)
+	if len(curves) != 2 || curves[0].workload != "teststruc" || curves[1].workload != "small" {
+		t.Fatalf("expected curves for teststruc and small, got %d", len(curves))
+	}
+	c := curves[0]
+	if len(c.points) != 2 || len(c.libs) != 2 {
+		t.Fatalf("expected 2 points and 2 libraries, got %v, %v", c.points, c.libs)
+	}
+	rows := c.rows("ns/byte")
+	if want := "Std_Json 0.4 -"; strings.Join(rows[2], " ") != want {
+		t.Fatalf("expected row %q, got %q", want, rows[2])
+	}
+	var buf bytes.Buffer
+	svgLineChart(&buf, c.title(), "encoded-bytes", c.points, c.series("encoded-bytes"), true)
+	if s := buf.String(); !strings.HasPrefix(s, "<svg ") || strings.Count(s, "<path ") != 3 {
+		t.Fatalf("unexpected svg: %s", s)
+	}
+}
+
+func TestBuildBoardsEncodedBytes(t *testing.T) {
+	results, err := benchfmt.Parse(strings.NewReader(`
+Benchmark__Encode/Json-8       100	  200 ns/op	 100 MB/s	 1005 encoded-bytes
+Benchmark__Encode/Std_Json-8   100	  400 ns/op	  50 MB/s	 1000 encoded-bytes
+Benchmark__Decode/Json-8       100	  400 ns/op	  50 MB/s	 1005 encoded-bytes
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boards := buildBoards(results, map[string]int{"json/teststruc": 900})
+	if len(boards) != 2 {
+		t.Fatalf("expected 2 boards, got %d", len(boards))
+	}
+	if e := boards[0].entries; e[0].size != 900 || e[1].size != 1000 {
+		t.Fatalf("expected sizes from the one-pass results, then encoded-bytes, got %d, %d", e[0].size, e[1].size)
+	}
+	if e := boards[1].entries; e[0].size != 900 {
+		t.Fatalf("expected decode size from the one-pass results only, got %d", e[0].size)
+	}
+}
+
+const testParallelOutput = `
+Benchmark__ParallelEncode/Json-4     100	  100 ns/op	 24 B/op	 1 allocs/op
+Benchmark__ParallelEncode/Json       100	  400 ns/op	 24 B/op	 1 allocs/op
+Benchmark__ParallelEncode/Json-2     100	  200 ns/op	 24 B/op	 1 allocs/op
+Benchmark__ParallelEncode/Std_Json   100	  800 ns/op	 80 B/op	 5 allocs/op
+`
+
+func TestBuildCurvesParallel(t *testing.T) {
+	results, err := benchfmt.Parse(strings.NewReader(testParallelOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if boards := buildBoards(results, nil); len(boards) != 0 {
+		t.Fatalf("expected no boards from parallel results, got %d", len(boards))
+	}
+	curves := buildCurves(results)
+	if len(curves) != 1 || curves[0].kind != "Parallel" {
+		t.Fatalf("expected 1 parallel curve, got %d", len(curves))
+	}
+	c := curves[0]
+	if strings.Join(c.points, ",") != "1,2,4" {
+		t.Fatalf("expected points sorted by GOMAXPROCS, got %v", c.points)
+	}
+	rows := c.rows("speedup")
+	if want := "Json 1 2 4"; strings.Join(rows[1], " ") != want {
+		t.Fatalf("expected row %q, got %q", want, rows[1])
+	}
+	if want := "Std_Json 1 - -"; strings.Join(rows[2], " ") != want {
+		t.Fatalf("expected row %q, got %q", want, rows[2])
+	}
+}
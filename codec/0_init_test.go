@@ -99,6 +99,7 @@ type testVars struct {
 	SkipRPCTests bool
 
 	UseIoWrapper bool
+	IoWrap       testIOWrap // how the IO wrappers split and delay calls (see testIOWrap)
 
 	NumRepeatString int
 
@@ -109,6 +110,33 @@ type testVars struct {
 
 	BenchmarkWithRuntimeMetrics bool
 
+	BenchmarkOnePassOutput string
+	BenchmarkOnePassFormat string
+
+	BenchmarkWorkloads string
+	BenchmarkSeed      int64
+
+	BenchmarkSweepDepths  string
+	BenchmarkSweepRepeats string
+
+	BenchmarkStreamRecords int
+
+	BenchmarkPipeMB          int
+	BenchmarkPipeBufferSizes string
+
+	BenchmarkRobustPrefixes int
+	BenchmarkRobustFlips    int
+	BenchmarkRobustOutput   string
+
+	BenchmarkBombLimitMB int
+
+	BenchmarkNestingDepths   string
+	BenchmarkDeepStrucDepths string
+
+	BenchmarkOptionPresets string
+
+	BenchmarkDeterministicRuns int
+
 	bufsize    testBufioSizeFlag
 	maxInitLen int
 	zeroCopy   bool
@@ -144,6 +172,9 @@ func testInitFlags() {
 	flag.Var(&testv.bufsize, "ti", "Use IO Reader/Writer for Marshal/Unmarshal ie >= 0")
 	flag.BoolVar(&testv.Verbose, "tv", false, "Text Extra Verbose Logging if -v if set")
 	flag.BoolVar(&testv.UseIoWrapper, "tiw", false, "Wrap the IO Reader/Writer with a base pass-through reader/writer")
+	flag.BoolVar(&testv.IoWrap.OneByte, "tiw1", false, "Wrap the IO Reader/Writer to read/write one byte per call (implies -tiw)")
+	flag.IntVar(&testv.IoWrap.MaxChunk, "tiwc", 0, "Wrap the IO Reader/Writer to read/write random chunks of 1 to N bytes per call (implies -tiw)")
+	flag.DurationVar(&testv.IoWrap.Latency, "tiwl", 0, "Wrap the IO Reader/Writer to sleep this long on each call (implies -tiw)")
 
 	flag.BoolVar(&testv.SkipIntf, "tf", false, "Skip Interfaces")
 	flag.BoolVar(&testv.UseReset, "tr", false, "Use Reset")
@@ -163,6 +194,23 @@ func testInitFlags() {
 func benchInitFlags() {
 	flag.BoolVar(&testv.BenchmarkNoConfig, "bnc", false, "benchmarks: do not make configuration changes for fair benchmarking")
 	flag.BoolVar(&testv.BenchmarkWithRuntimeMetrics, "brm", false, "benchmarks: include runtime metrics")
+	flag.StringVar(&testv.BenchmarkOnePassOutput, "bo", "", "benchmarks: write one-pass check results to this file")
+	flag.StringVar(&testv.BenchmarkOnePassFormat, "bof", "json", "benchmarks: format of one-pass check results file: json or csv")
+	flag.StringVar(&testv.BenchmarkWorkloads, "bw", "teststruc", "benchmarks: comma-separated workloads to run e.g. small,numeric,strings,nested,maps (or all)")
+	flag.Int64Var(&testv.BenchmarkSeed, "seed", 0, "benchmarks: if non-zero, fill benchmark values with random data generated from this seed")
+	flag.StringVar(&testv.BenchmarkSweepDepths, "bsd", "0,1,2,3", "benchmarks: comma-separated depths swept by Benchmark__Sweep")
+	flag.StringVar(&testv.BenchmarkSweepRepeats, "bsr", "1,8,32", "benchmarks: comma-separated string repeat factors swept by Benchmark__Sweep")
+	flag.IntVar(&testv.BenchmarkStreamRecords, "bsn", 100, "benchmarks: number of records per stream in the streaming benchmarks")
+	flag.IntVar(&testv.BenchmarkPipeMB, "bpm", 256, "benchmarks: approximate size in MB of each stream in Benchmark__Pipe")
+	flag.StringVar(&testv.BenchmarkPipeBufferSizes, "bpb", "0,4096,65536", "benchmarks: comma-separated reader/writer buffer sizes used by Benchmark__Pipe")
+	flag.IntVar(&testv.BenchmarkRobustPrefixes, "brp", 256, "benchmarks: max number of truncated prefixes decoded per library by TestBenchRobustness (0 for all)")
+	flag.IntVar(&testv.BenchmarkRobustFlips, "brf", 128, "benchmarks: number of random byte flips decoded per library by TestBenchRobustness")
+	flag.StringVar(&testv.BenchmarkRobustOutput, "bro", "", "benchmarks: write the TestBenchRobustness table to this file (markdown)")
+	flag.IntVar(&testv.BenchmarkBombLimitMB, "bbl", 1024, "benchmarks: max MB by which each decode in TestBenchAllocBomb may grow the address space, before it is out of memory")
+	flag.StringVar(&testv.BenchmarkNestingDepths, "bnd", "10001,1000000", "benchmarks: comma-separated nesting depths of the arrays and maps decoded by TestBenchDeepNesting")
+	flag.StringVar(&testv.BenchmarkDeepStrucDepths, "bdd", "1,3,5", "benchmarks: comma-separated depths of the TestStruc trees in Benchmark__DeepStruc")
+	flag.StringVar(&testv.BenchmarkOptionPresets, "bop", "all", "benchmarks: comma-separated codec option presets run by BenchmarkCodecOptionsSuite e.g. none,canonical,zero-copy (or all)")
+	flag.IntVar(&testv.BenchmarkDeterministicRuns, "bdn", 64, "benchmarks: number of times TestBenchDeterministic encodes the value with each library")
 	// flags reproduced here for compatibility (duplicate some in testInitFlags)
 	flag.BoolVar(&testv.MapStringKeyOnly, "bs", false, "benchmarks: use maps with string keys only")
 	flag.IntVar(&testv.Depth, "bd", 1, "Benchmarks: Test Struc Depth")
@@ -7,7 +7,12 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"math/rand"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	// using codec.XXX directly
 	. "github.com/ugorji/go/codec"
@@ -64,6 +69,208 @@ func (x ioWriterWrapper) Write(p []byte) (n int, err error) {
 	return x.w.Write(p)
 }
 
+// testIOWrap configures how the IO wrappers split and delay reads and writes,
+// to simulate network-like IO. The zero value is a pass-through.
+type testIOWrap struct {
+	OneByte  bool          // read/write one byte per call
+	MaxChunk int           // if > 0, read/write a random number of bytes (1 to MaxChunk) per call
+	Latency  time.Duration // if > 0, sleep this long on each call
+}
+
+func (x testIOWrap) passThrough() bool {
+	return !x.OneByte && x.MaxChunk <= 0 && x.Latency <= 0
+}
+
+// String returns a name for use in benchmark and test names e.g. byte, chunk512-lat20us.
+func (x testIOWrap) String() string {
+	var v []string
+	if x.OneByte {
+		v = append(v, "byte")
+	} else if x.MaxChunk > 0 {
+		v = append(v, "chunk"+strconv.Itoa(x.MaxChunk))
+	}
+	if x.Latency > 0 {
+		v = append(v, "lat"+strings.Replace(x.Latency.String(), "µ", "u", 1))
+	}
+	if len(v) == 0 {
+		return "pass"
+	}
+	return strings.Join(v, "-")
+}
+
+// reader returns r wrapped as configured.
+func (x testIOWrap) reader(r io.Reader) io.Reader {
+	if x.passThrough() {
+		return ioReaderWrapper{r}
+	}
+	return &ioFragmentReader{r: r, ioFragmenter: x.fragmenter(false)}
+}
+
+// writer returns w wrapped as configured.
+func (x testIOWrap) writer(w io.Writer) io.Writer {
+	if x.passThrough() {
+		return ioWriterWrapper{w}
+	}
+	return &ioFragmentWriter{w: w, ioFragmenter: x.fragmenter(false)}
+}
+
+// readerFn returns a func which returns r wrapped as configured, like reader,
+// but which reuses one wrapper (with its own random source) across calls,
+// so wrapping costs nothing per call e.g. in a benchmark loop. It is not safe for concurrent use.
+func (x testIOWrap) readerFn() func(r io.Reader) io.Reader {
+	if x.passThrough() {
+		v := new(ioReaderWrapper)
+		return func(r io.Reader) io.Reader { v.r = r; return v }
+	}
+	v := &ioFragmentReader{ioFragmenter: x.fragmenter(true)}
+	return func(r io.Reader) io.Reader { v.r = r; return v }
+}
+
+// writerFn returns a func which returns w wrapped as configured, like writer,
+// but which reuses one wrapper (with its own random source) across calls (see readerFn).
+func (x testIOWrap) writerFn() func(w io.Writer) io.Writer {
+	if x.passThrough() {
+		v := new(ioWriterWrapper)
+		return func(w io.Writer) io.Writer { v.w = w; return v }
+	}
+	v := &ioFragmentWriter{ioFragmenter: x.fragmenter(true)}
+	return func(w io.Writer) io.Writer { v.w = w; return v }
+}
+
+// fragmenter returns an ioFragmenter which draws chunk sizes (only used with MaxChunk)
+// from a new source if own, else from the shared testIOWrapRand.
+// Either way, the source is seeded, so that chunk sizes are reproducible.
+func (x testIOWrap) fragmenter(own bool) ioFragmenter {
+	f := ioFragmenter{testIOWrap: x}
+	switch {
+	case x.MaxChunk <= 0:
+	case own:
+		f.rand = rand.New(rand.NewSource(1))
+	default:
+		f.rand = testIOWrapRand
+	}
+	return f
+}
+
+// testIOWrapRand is the random source shared by the wrappers returned by reader and writer,
+// as creating a seeded source costs about as much as a small decode.
+// It is safe for concurrent use, as those wrappers may be created and used in parallel.
+var testIOWrapRand = rand.New(&testLockedRandSource{src: rand.NewSource(1)})
+
+// testLockedRandSource is a rand.Source which is safe for concurrent use.
+type testLockedRandSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (x *testLockedRandSource) Int63() (n int64) {
+	x.mu.Lock()
+	n = x.src.Int63()
+	x.mu.Unlock()
+	return
+}
+
+func (x *testLockedRandSource) Seed(seed int64) {
+	x.mu.Lock()
+	x.src.Seed(seed)
+	x.mu.Unlock()
+}
+
+// ioFragmenter splits and delays calls, as configured by its testIOWrap.
+type ioFragmenter struct {
+	testIOWrap
+	rand *rand.Rand // nil unless MaxChunk > 0
+}
+
+// next sleeps (if configured), and returns how many of the n bytes to read or write in this call.
+func (x *ioFragmenter) next(n int) int {
+	if x.Latency > 0 {
+		time.Sleep(x.Latency)
+	}
+	if x.OneByte {
+		return min(n, 1)
+	}
+	if x.MaxChunk > 0 {
+		return min(n, 1+x.rand.Intn(x.MaxChunk))
+	}
+	return n
+}
+
+// ioFragmentReader returns short reads from r.
+type ioFragmentReader struct {
+	r io.Reader
+	ioFragmenter
+}
+
+func (x *ioFragmentReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return x.r.Read(p)
+	}
+	return x.r.Read(p[:x.next(len(p))])
+}
+
+// ioFragmentWriter writes p to w over multiple calls.
+// As io.Writer does not allow short writes, the caller sees a single full write.
+type ioFragmentWriter struct {
+	w io.Writer
+	ioFragmenter
+}
+
+func (x *ioFragmentWriter) Write(p []byte) (n int, err error) {
+	for n < len(p) && err == nil {
+		var i int
+		i, err = x.w.Write(p[n : n+x.next(len(p)-n)])
+		n += i
+	}
+	return
+}
+
+// errTestIOInjected is the error returned by the failing IO wrappers (when not given another).
+var errTestIOInjected = errors.New("injected io error")
+
+// ioFailingWriter writes up to n bytes to w, then fails with err.
+type ioFailingWriter struct {
+	w   io.Writer
+	n   int
+	err error
+}
+
+func (x *ioFailingWriter) Write(p []byte) (n int, err error) {
+	if len(p) > x.n {
+		p, err = p[:x.n], x.err
+	}
+	n, werr := x.w.Write(p)
+	x.n -= n
+	if werr != nil {
+		err = werr
+	}
+	return
+}
+
+// ioFailingReader reads up to n bytes from r, then fails with err.
+type ioFailingReader struct {
+	r   io.Reader
+	n   int
+	err error
+}
+
+func (x *ioFailingReader) Read(p []byte) (n int, err error) {
+	if x.n <= 0 {
+		return 0, x.err
+	}
+	if len(p) > x.n {
+		p = p[:x.n]
+	}
+	n, err = x.r.Read(p)
+	x.n -= n
+	return
+}
+
+// testUseIoWrapper returns whether the IO Reader/Writer are wrapped (see -tiw and testIOWrap).
+func testUseIoWrapper() bool {
+	return testv.UseIoWrapper || !testv.IoWrap.passThrough()
+}
+
 // the handles are declared here, and initialized during the init function.
 //
 // Note the following:
@@ -154,31 +361,48 @@ func testHEDGet(h Handle) (d *testHED) {
 	return
 }
 
+// encoder returns the io or bytes Encoder, based on the configured buffer size.
+func (x *testHED) encoder() *Encoder {
+	if tbvars.E.WriterBufferSize >= 0 {
+		return x.Eio
+	}
+	return x.Eb
+}
+
+// decoder returns the io or bytes Decoder, based on the configured buffer size.
+func (x *testHED) decoder() *Decoder {
+	if tbvars.D.ReaderBufferSize >= 0 {
+		return x.Dio
+	}
+	return x.Db
+}
+
 func testSharedCodecEncode(ts interface{}, bsIn []byte,
 	fn func([]byte) *bytes.Buffer,
 	h Handle, useMust bool) (bs []byte, err error) {
 	// bs = make([]byte, 0, approxSize)
 	var e *Encoder
-	var buf *bytes.Buffer
 	useIO := tbvars.E.WriterBufferSize >= 0
 	if testv.UseReset && !testv.UseParallel {
-		hed := testHEDGet(h)
-		if useIO {
-			e = hed.Eio
-		} else {
-			e = hed.Eb
-		}
+		e = testHEDGet(h).encoder()
 	} else if useIO {
 		e = NewEncoder(nil, h)
 	} else {
 		e = NewEncoderBytes(nil, h)
 	}
+	return testCodecEncode(e, ts, bsIn, fn, useMust)
+}
 
+// testCodecEncode encodes ts using e, into bsIn (or, if using IO, into the buffer returned by fn(bsIn)).
+func testCodecEncode(e *Encoder, ts interface{}, bsIn []byte,
+	fn func([]byte) *bytes.Buffer, useMust bool) (bs []byte, err error) {
+	var buf *bytes.Buffer
+	useIO := tbvars.E.WriterBufferSize >= 0
 	// var oldWriteBufferSize int
 	if useIO {
 		buf = fn(bsIn)
-		if testv.UseIoWrapper {
-			e.Reset(ioWriterWrapper{buf})
+		if testUseIoWrapper() {
+			e.Reset(testv.IoWrap.writer(buf))
 		} else {
 			e.Reset(buf)
 		}
@@ -201,32 +425,36 @@ func testSharedCodecDecoder(bs []byte, h Handle) (d *Decoder) {
 	// var buf *bytes.Reader
 	useIO := tbvars.D.ReaderBufferSize >= 0
 	if testv.UseReset && !testv.UseParallel {
-		hed := testHEDGet(h)
-		if useIO {
-			d = hed.Dio
-		} else {
-			d = hed.Db
-		}
+		d = testHEDGet(h).decoder()
 	} else if useIO {
 		d = NewDecoder(nil, h)
 	} else {
 		d = NewDecoderBytes(nil, h)
 	}
-	if useIO {
+	testCodecDecoderReset(d, bs)
+	return
+}
+
+// testCodecDecoderReset resets d to decode from bs (via a reader if using IO).
+func testCodecDecoderReset(d *Decoder, bs []byte) {
+	if tbvars.D.ReaderBufferSize >= 0 {
 		buf := bytes.NewReader(bs)
-		if testv.UseIoWrapper {
-			d.Reset(ioReaderWrapper{buf})
+		if testUseIoWrapper() {
+			d.Reset(testv.IoWrap.reader(buf))
 		} else {
 			d.Reset(buf)
 		}
 	} else {
 		d.ResetBytes(bs)
 	}
-	return
 }
 
 func testSharedCodecDecode(bs []byte, ts interface{}, h Handle, useMust bool) (err error) {
-	d := testSharedCodecDecoder(bs, h)
+	return testCodecDecode(testSharedCodecDecoder(bs, h), ts, useMust)
+}
+
+// testCodecDecode decodes into ts using d (which has been reset to the input).
+func testCodecDecode(d *Decoder, ts interface{}, useMust bool) (err error) {
 	if useMust {
 		d.MustDecode(ts)
 	} else {
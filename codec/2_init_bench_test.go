@@ -38,6 +38,7 @@ package codec
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"reflect"
 	"runtime"
 	"runtime/metrics"
@@ -66,10 +67,322 @@ type benchEncFn func(interface{}, []byte) ([]byte, error)
 type benchDecFn func([]byte, interface{}) error
 type benchIntfFn func() interface{}
 
+// benchStreamFn encodes (or decodes) the next record of a stream.
+type benchStreamFn func(interface{}) error
+
+// benchFormat is the wire format family of a benchChecker.
+//
+// Checkers in the json, cbor and msgpack families decode
+// bytes produced by the codec encoder for that family (see fnBenchmarkDecode).
+type benchFormat string
+
+const (
+	benchFormatMsgpack benchFormat = "msgpack"
+	benchFormatBinc    benchFormat = "binc"
+	benchFormatSimple  benchFormat = "simple"
+	benchFormatCbor    benchFormat = "cbor"
+	benchFormatJson    benchFormat = "json"
+	benchFormatGob     benchFormat = "gob"
+	benchFormatXml     benchFormat = "xml"
+	benchFormatBson    benchFormat = "bson"
+	benchFormatXdr     benchFormat = "xdr"
+	benchFormatSereal  benchFormat = "sereal"
+)
+
+// benchGroup identifies where a benchChecker comes from,
+// which determines the build tags it needs and the suites it belongs to.
+type benchGroup uint8
+
+const (
+	benchGroupCodec  benchGroup = iota // github.com/ugorji/go/codec
+	benchGroupStdlib                   // go standard library
+	benchGroupX                        // external libraries (tag: x)
+	benchGroupXGen                     // external libraries using code generation (tags: x generated)
+)
+
+func (x benchGroup) String() string {
+	switch x {
+	case benchGroupCodec:
+		return "codec"
+	case benchGroupStdlib:
+		return "stdlib"
+	case benchGroupX:
+		return "x"
+	case benchGroupXGen:
+		return "x-gen"
+	}
+	return "unknown"
+}
+
+// tags returns the build tags required to include checkers of this group.
+func (x benchGroup) tags() string {
+	switch x {
+	case benchGroupX:
+		return "x"
+	case benchGroupXGen:
+		return "x generated"
+	}
+	return ""
+}
+
+// benchChecker is the registration for a library (or codec handle) we benchmark.
+//
+// Each file registers its checkers into benchCheckers during pre-init,
+// and the benchmarks and suites are derived from that registry.
+// Adding a library should only require registering it.
 type benchChecker struct {
-	name     string
+	name   string      // short name used in logs e.g. std-json
+	title  string      // name used in benchmark names e.g. Std_Json
+	format benchFormat // wire format family
+	group  benchGroup
+
 	encodefn benchEncFn
 	decodefn benchDecFn
+
+	// newenc and newdec, if set, return an encode (or decode) function bound to a new
+	// encoder (or decoder) which is reset on each call e.g. for codec, an Encoder sharing the Handle.
+	// Each returned function may only be used by a single goroutine.
+	//
+	// They are used by the parallel benchmarks (one per goroutine) and the reuse strategies (see benchStrategy).
+	// Otherwise, parallel benchmarks call encodefn and decodefn from all goroutines.
+	newenc func() benchEncFn
+	newdec func() benchDecFn
+
+	// streamenc and streamdec, if set, return a function which encodes records back-to-back to w
+	// (or decodes them one at a time from r) using a single encoder (or decoder),
+	// in the library's stream framing e.g. NDJSON, a CBOR sequence (RFC 8742) or a gob stream.
+	// They are used by the streaming benchmarks (see Benchmark__StreamEncode).
+	streamenc func(w io.Writer) benchStreamFn
+	streamdec func(r io.Reader) benchStreamFn
+
+	// detenc, if set, encodes in the library's deterministic mode e.g. with map keys sorted,
+	// so equal values always encode to the same bytes (see TestBenchDeterministic).
+	// For libraries which are always deterministic, it is encodefn.
+	detenc benchEncFn
+
+	// owndec, if set, makes decode benchmarks decode this library's own encoding,
+	// instead of codec's for the format (see benchDecodeEncodeFn).
+	owndec bool
+
+	// caps are the capabilities of this library (see benchCap).
+	caps benchCap
+
+	// skip, if non-empty, is the reason why this checker
+	// is excluded from running, regardless of its capabilities.
+	skip string
+}
+
+// benchCap is a set of capabilities i.e. shapes of go values
+// which a library can encode and decode faithfully.
+//
+// Each benchChecker declares its capabilities, and the harness compares
+// them against what the benchmark value requires (see benchWorkload).
+// A missing capability causes the checker to be skipped (with the reason),
+// unless it is adaptable (see benchCapAdaptable).
+type benchCap uint16
+
+const (
+	benchCapNonStringMapKeys    benchCap = 1 << iota // maps with non-string keys e.g. map[int]int
+	benchCapUint64AboveMaxInt64                      // uint64 values > math.MaxInt64
+	benchCapNilInPtrSlice                            // nil elements in slice of pointers e.g. []*int64{nil}
+	benchCapNilVsEmpty                               // nil and empty slices/maps decode distinctly
+	benchCapFixedArrays                              // fixed-size arrays e.g. [8]int64
+	benchCapEmbeddedStructs                          // embedded (anonymous) struct fields
+	benchCapMaps                                     // maps with string keys
+	benchCapPtrMapValues                             // maps with pointer values e.g. map[string]*T
+	benchCapNilPtrs                                  // nil pointer fields
+	benchCapNestedSlices                             // slices of slices e.g. [][]float64 (excluding [][]byte)
+	benchCapStructPtrSlice                           // slices of pointers to structs e.g. []*T
+	benchCapScalarPtrs                               // non-nil pointers to scalars e.g. *string, *bool
+	benchCapPtrToZero                                // non-nil pointers to zero values e.g. *bool pointing to false
+
+	benchCapAll = benchCapPtrToZero<<1 - 1
+)
+
+// benchCapAdaptable are the capabilities which the harness can adapt to,
+// so a checker missing them is not skipped.
+//
+// For nil-vs-empty, we consider nil and empty slices/maps equal when comparing.
+const benchCapAdaptable = benchCapNilVsEmpty
+
+var benchCapNames = [...]string{
+	"non-string-map-keys",
+	"uint64-above-maxint64",
+	"nil-in-ptr-slice",
+	"nil-vs-empty",
+	"fixed-arrays",
+	"embedded-structs",
+	"maps",
+	"ptr-map-values",
+	"nil-ptrs",
+	"nested-slices",
+	"struct-ptr-slice",
+	"scalar-ptrs",
+	"ptr-to-zero",
+}
+
+func (x benchCap) String() string {
+	var s []string
+	for i, n := range benchCapNames {
+		if x&(1<<i) != 0 {
+			s = append(s, n)
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// testStrucCaps returns the capabilities required to handle
+// a TestStruc populated with the given parameters (see populateTestStruc).
+func testStrucCaps(depth int, bench, useStringKeyOnly bool) (v benchCap) {
+	// AnonInTestStruc has fixed arrays, and is embedded.
+	// Nmap/Nslice/Nint64 are nil (and some slices/maps are empty).
+	v = benchCapFixedArrays | benchCapEmbeddedStructs | benchCapMaps |
+		benchCapNilVsEmpty | benchCapNilPtrs
+	if depth > 0 {
+		v |= benchCapPtrMapValues | benchCapStructPtrSlice // Mtsptr, MptrstrUi64T, Its
+	}
+	if !bench {
+		v |= benchCapUint64AboveMaxInt64 | benchCapNilInPtrSlice
+	}
+	// Note: populateTestStrucCommon does not currently add non-string keys
+	// even if !useStringKeyOnly, so we do not require benchCapNonStringMapKeys.
+	return
+}
+
+// skipReason returns why this checker cannot run against the workload, or "" if it can.
+func (x *benchChecker) skipReason(w *benchWorkload) string {
+	if x.skip != "" {
+		return x.skip
+	}
+	// code is only generated for the types in values_test.go (see bench.sh -c)
+	if x.group == benchGroupXGen && w.name != benchWorkloadDefault {
+		return x.name + " has no generated code for workload: " + w.name
+	}
+	if missing := w.vcaps &^ x.caps &^ benchCapAdaptable; missing != 0 {
+		return x.name + " cannot handle workload " + w.name + ": missing capabilities: " + missing.String()
+	}
+	return ""
+}
+
+// runnable returns true if this checker can run against any of the selected workloads.
+func (x *benchChecker) runnable() bool {
+	for _, w := range benchWorkloadsSelected {
+		if x.skipReason(w) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// adapted returns the adaptable capabilities which the workload requires,
+// but which this checker is missing.
+func (x *benchChecker) adapted(w *benchWorkload) benchCap {
+	return w.vcaps &^ x.caps & benchCapAdaptable
+}
+
+// benchName returns the name of the benchmark for this checker
+// e.g. Benchmark__Std_Json___Encode (for op=Encode).
+//
+// The title is padded with '_' so that results line up.
+func (x *benchChecker) benchName(op string) string {
+	const width = 11
+	n := width - len(x.title)
+	if n < 2 {
+		n = 2
+	}
+	return "Benchmark__" + x.title + strings.Repeat("_", n) + op
+}
+
+func (x *benchChecker) benchEncode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		fnBenchmarkEncode(b, x.name, w.v, x.encodefn, w.baseLen)
+	})
+}
+
+func (x *benchChecker) benchDecode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		fnBenchmarkDecode(b, x.name, w.v, benchDecodeEncodeFn(x), x.decodefn, w.newfn, w.baseLen)
+	})
+}
+
+// benchWorkloads runs fn against each selected workload.
+//
+// If only the default workload is selected, fn runs directly (so benchmark names are unchanged).
+// Otherwise, each workload runs as a sub-benchmark named after it.
+func (x *benchChecker) benchWorkloads(b *testing.B, fn func(*testing.B, *benchWorkload)) {
+	run := func(b *testing.B, w *benchWorkload) {
+		if reason := x.skipReason(w); reason != "" {
+			b.Skip(reason)
+		}
+		fn(b, w)
+	}
+	if benchWorkloadsIsDefault() {
+		run(b, benchWorkloadsSelected[0])
+		return
+	}
+	for _, w := range benchWorkloadsSelected {
+		b.Run(w.name, func(b *testing.B) { run(b, w) })
+	}
+}
+
+// benchCheckersFor returns the registered checkers which satisfy the filter fn.
+// A nil fn matches all checkers.
+func benchCheckersFor(fn func(*benchChecker) bool) (v []*benchChecker) {
+	for i := range benchCheckers {
+		if fn == nil || fn(&benchCheckers[i]) {
+			v = append(v, &benchCheckers[i])
+		}
+	}
+	return
+}
+
+// benchFormatEncodeFn returns the codec encoder for the format family,
+// or nil if codec does not support that format.
+func benchFormatEncodeFn(f benchFormat) benchEncFn {
+	switch f {
+	case benchFormatJson:
+		return fnJsonEncodeFn
+	case benchFormatCbor:
+		return fnCborEncodeFn
+	case benchFormatMsgpack:
+		return fnMsgpackEncodeFn
+	case benchFormatBinc:
+		return fnBincEncodeFn
+	case benchFormatSimple:
+		return fnSimpleEncodeFn
+	}
+	return nil
+}
+
+// benchBaseline is the checker which encoded sizes are compared against.
+const benchBaseline = "std-json"
+
+// benchBaselineLen returns the length of the workload's value encoded by the baseline,
+// or 0 if the baseline is not registered (e.g. with the generated tag) or cannot handle it.
+func benchBaselineLen(w *benchWorkload) int {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.name == benchBaseline }) {
+		if bc.skipReason(w) != "" {
+			return 0
+		}
+		if bs, err := bc.encodefn(w.v, nil); err == nil {
+			return len(bs)
+		}
+	}
+	return 0
+}
+
+// benchDecodeEncodeFn returns the encoder which produces the bytes that decode benchmarks
+// of the checker decode. To ensure the same sequence of bytes is decoded by all libraries
+// of the json, cbor and msgpack format families, it is codec's encoder for the format
+// (unless the checker sets owndec).
+func benchDecodeEncodeFn(bc *benchChecker) benchEncFn {
+	switch bc.format {
+	case benchFormatJson, benchFormatCbor, benchFormatMsgpack:
+		if !bc.owndec {
+			return benchFormatEncodeFn(bc.format)
+		}
+	}
+	return bc.encodefn
 }
 
 func init() {
@@ -80,13 +393,29 @@ func init() {
 }
 
 func benchInit() {
+	var err error
+	if benchWorkloadsSelected, err = benchWorkloadsSelect(testv.BenchmarkWorkloads); err != nil {
+		panic(err)
+	}
+	benchInitValues()
+	benchUpdateHandles()
+}
+
+// benchInitValues (re)creates the benchmark values for the current
+// depth and string repeat factor (see testv.Depth and testv.NumRepeatString).
+func benchInitValues() {
 	benchTs = newTestStruc(testv.Depth, testv.NumRepeatString, true, !testv.SkipIntf, testv.MapStringKeyOnly)
+	for _, w := range benchWorkloads {
+		w.init()
+	}
+	for _, w := range benchWorkloads {
+		w.baseLen = benchBaselineLen(w)
+	}
 	approxSize = approxDataSize(reflect.ValueOf(benchTs)) * 2 // multiply by 1.5 or 2 to appease msgp, and prevent alloc
 	// bytesLen := 1024 * 4 * (testv.Depth + 1) * (testv.Depth + 1)
 	// if bytesLen < approxSize {
 	// 	bytesLen = approxSize
 	// }
-	benchUpdateHandles()
 }
 
 func benchReinit() {
@@ -134,13 +463,27 @@ func TestBenchOnePassCheck(t *testing.T) {
 	if approxSize > 0 {
 		benchOnePassLogf("\tApproxDeepSize Of benchmark Struct: %d bytes", approxSize)
 	}
+	if testv.BenchmarkSeed != 0 {
+		benchOnePassLogf("\tRandom values seed:                 %d", testv.BenchmarkSeed)
+	}
 	if benchUnscientificRes {
 		benchOnePassLogf("Benchmark One-Pass Run (with Unscientific Encode/Decode times): ")
 	} else {
 		benchOnePassLogf("Benchmark One-Pass Run:")
 	}
-	for _, bc := range benchCheckers {
-		benchOnePassCheck(t, bc.name, bc.encodefn, bc.decodefn)
+	var results []benchOnePassResult
+	for _, w := range benchWorkloadsSelected {
+		if !benchWorkloadsIsDefault() {
+			benchOnePassLogf("Workload: %s: %s", w.name, w.desc)
+		}
+		for i := range benchCheckers {
+			results = append(results, benchOnePassCheck(t, &benchCheckers[i], w))
+		}
+	}
+	if testv.BenchmarkOnePassOutput != "" {
+		if err := benchOnePassWriteResults(testv.BenchmarkOnePassOutput, testv.BenchmarkOnePassFormat, results); err != nil {
+			t.Errorf("error writing one-pass results: %v", err)
+		}
 	}
 	if testv.Verbose {
 		benchOnePassLogf("..............................................")
@@ -150,50 +493,93 @@ func TestBenchOnePassCheck(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 }
 
-func benchOnePassCheck(t *testing.T, name string, encfn benchEncFn, decfn benchDecFn) {
+func benchOnePassCheck(t *testing.T, bc *benchChecker, w *benchWorkload) (r benchOnePassResult) {
 	// if benchUnscientificRes {
 	// 	benchOnePassLogf("-------------- %s ----------------", name)
 	// }
-	defer benchOnePassRecoverPanic(name)
-	defer func(b bool) { testv.UseDiff = b }(testv.UseDiff)
-	testv.UseDiff = true // show diffs if not equal
+	name, encfn, decfn := bc.name, bc.encodefn, bc.decodefn
+	r = benchOnePassResult{Name: name, Workload: w.name, Depth: testv.Depth}
+	if reason := bc.skipReason(w); reason != "" {
+		r.Skipped = reason
+		benchOnePassLogf("\t%10s: **** Skipped: %s", name, reason)
+		return
+	}
+	defer benchOnePassRecoverPanic(&r)
 	runtime.GC()
 	tnow := time.Now()
-	buf, err := encfn(benchTs, nil)
+	buf, err := encfn(w.v, nil)
 	if err != nil {
-		benchOnePassLogf("\t%10s: **** Error encoding benchTs: %v", name, err)
+		r.Error = err.Error()
+		benchOnePassLogf("\t%10s: **** Error encoding %s: %v", name, w.name, err)
 		return
 	}
 	encDur := time.Since(tnow)
 	encLen := len(buf)
+	r.EncodedLen, r.EncodeNs = encLen, encDur.Nanoseconds()
 	runtime.GC()
 	if !benchUnscientificRes {
 		benchOnePassLogf("\t%10s: len: %d bytes\n", name, encLen)
 		return
 	}
 	tnow = time.Now()
-	var ts2 TestStruc
-	if err = decfn(buf, &ts2); err != nil {
-		benchOnePassLogf("\t%10s: **** Error decoding into new TestStruc: %v", name, err)
+	ts2 := w.new()
+	if err = decfn(buf, ts2); err != nil {
+		r.Error = err.Error()
+		benchOnePassLogf("\t%10s: **** Error decoding into new %v: %v", name, w.typ, err)
 		return
 	}
 	decDur := time.Since(tnow)
-	benchOnePassLogf("\t%10s: len: %d bytes,\t encode: %v,\t decode: %v, diff: %v", name, encLen, encDur, decDur, testEqualOpts(benchTs, &ts2, true, nil))
+	r.DecodeNs = decDur.Nanoseconds()
+	var adaptedMsg string
+	adapted := bc.adapted(w)
+	if adapted != 0 {
+		r.Adapted = adapted.String()
+		adaptedMsg = " (adapted: " + r.Adapted + ")"
+	}
+	r.Mismatches = testDiff(w.v, ts2, adapted&benchCapNilVsEmpty != 0)
+	r.Equal = len(r.Mismatches) == 0
+	var diffMsg = "<nil>"
+	if !r.Equal {
+		diffMsg = r.Mismatches.summary()
+	}
+	benchOnePassLogf("\t%10s: len: %d bytes,\t encode: %v,\t decode: %v, diff: %v%s", name, encLen, encDur, decDur,
+		diffMsg, adaptedMsg)
+	if !r.Equal {
+		benchOnePassLogf("\t%10s: %v", "", r.Mismatches)
+	}
 	// if benchCheckDoDeepEqual {
+	return
 }
 
 func benchOnePassLogf(format string, args ...interface{}) {
 	fmt.Printf(format+"\n", args...)
 }
 
-func benchOnePassRecoverPanic(name string) {
+func benchOnePassRecoverPanic(res *benchOnePassResult) {
 	if benchRecover {
 		if r := recover(); r != nil {
-			benchOnePassLogf("\t%10s: (recovered) panic: %v", name, r)
+			res.Panic = fmt.Sprint(r)
+			benchOnePassLogf("\t%10s: (recovered) panic: %v", res.Name, r)
 		}
 	}
 }
 
+// Benchmark__Encode and Benchmark__Decode run a sub-benchmark for each registered checker.
+//
+// Run a single one using its title e.g. -bench '__Encode/^Std_Json$'
+
+func Benchmark__Encode(b *testing.B) {
+	for _, bc := range benchCheckersFor(nil) {
+		b.Run(bc.title, bc.benchEncode)
+	}
+}
+
+func Benchmark__Decode(b *testing.B) {
+	for _, bc := range benchCheckersFor(nil) {
+		b.Run(bc.title, bc.benchDecode)
+	}
+}
+
 var vBenchTs = TestStruc{}
 
 func fnBenchNewTs() interface{} {
@@ -221,69 +607,65 @@ func benchRecoverPanic(t *testing.B) {
 	}
 }
 
-func fnBenchmarkEncode(b *testing.B, encName string, ts interface{}, encfn benchEncFn) {
+// fnBenchmarkReportSize reports the throughput (MB/s) of a benchmark over n encoded bytes,
+// as well as n (encoded-bytes) and its size relative to the baseline (size/std-json) if baseLen > 0.
+//
+// It must be called after the benchmark loop, as b.ResetTimer clears reported metrics.
+func fnBenchmarkReportSize(b *testing.B, n, baseLen int) {
+	b.SetBytes(int64(n))
+	b.ReportMetric(float64(n), "encoded-bytes")
+	if baseLen > 0 {
+		b.ReportMetric(float64(n)/float64(baseLen), "size/"+benchBaseline)
+	}
+}
+
+func fnBenchmarkEncode(b *testing.B, encName string, ts interface{}, encfn benchEncFn, baseLen int) {
 	defer benchRecoverPanic(b)
 	// testOnce.Do(testInitAll)
-	// ignore method params: ts, and work on benchTs directly
-	ts = benchTs
 	// do initial warm up by running encode one time
 	bs, err := encfn(ts, make([]byte, 0, approxSize))
+	if err != nil {
+		b.Logf("Error encoding %T: %s: %v", ts, encName, err)
+		b.FailNow()
+	}
 	// var err error
 	// bs := make([]byte, 0, approxSize)
 	fnRun := func() {
 		if _, err = encfn(ts, bs); err != nil {
-			b.Logf("Error encoding benchTs: %s: %v", encName, err)
+			b.Logf("Error encoding %T: %s: %v", ts, encName, err)
 			b.FailNow()
 		}
 	}
 	fnRun()
 	fnBenchmarkRun(b, fnRun)
+	fnBenchmarkReportSize(b, len(bs), baseLen)
 }
 
+// fnBenchmarkDecode benchmarks decoding the bytes which encfn encodes (see benchDecodeEncodeFn).
 func fnBenchmarkDecode(b *testing.B, encName string, ts interface{},
-	encfn benchEncFn, decfn benchDecFn, newfn benchIntfFn,
+	encfn benchEncFn, decfn benchDecFn, newfn benchIntfFn, baseLen int,
 ) {
 	defer benchRecoverPanic(b)
 	// testOnce.Do(testInitAll)
 
-	// MARKER: to ensure same sequence of bytes to be decoded, always encode using codec encoder.
-	//
-	// ignore method params:
-	// - ts: use benchTs instead
-	// - newfn: use TestStruc instead
-	// - benchEncFn: use codec's encfn instead (based on name/format)
-
-	ts = benchTs
-
-	if strings.Contains(encName, "json") {
-		encfn = fnJsonEncodeFn
-	} else if strings.Contains(encName, "cbor") {
-		encfn = fnCborEncodeFn
-	} else if strings.Contains(encName, "msgpack") {
-		encfn = fnMsgpackEncodeFn
-	}
-
 	buf := make([]byte, 0, approxSize)
 	buf, err := encfn(ts, buf)
 	if err != nil {
-		b.Logf("Error encoding benchTs: %s: %v", encName, err)
+		b.Logf("Error encoding %T: %s: %v", ts, encName, err)
 		b.FailNow()
 	}
 
 	// do initial warm up by running decode one time
-	locTs := new(TestStruc)
-	ts = locTs
-
 	fnRun := func() {
-		*locTs = TestStruc{}
-		if err = decfn(buf, ts); err != nil {
-			b.Logf("Error decoding into new TestStruc: %s: %v", encName, err)
+		if err = decfn(buf, newfn()); err != nil {
+			b.Logf("Error decoding into new %T: %s: %v", ts, encName, err)
 			b.FailNow()
 		}
 	}
 
 	fnRun()
 	fnBenchmarkRun(b, fnRun)
+	fnBenchmarkReportSize(b, len(buf), baseLen)
 
 	// if false && benchVerify { // do not do benchVerify during decode
 	// 	// ts2 := newfn()
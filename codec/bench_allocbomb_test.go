@@ -0,0 +1,406 @@
+//go:build !codec.nobench && !nobench && go1.24 && linux
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file checks how each decoder copes with allocation bombs: tiny inputs which declare
+// a huge array, map, string or byte slice (e.g. 4294967295 elements, followed by 1 byte).
+//
+// The inputs are crafted in each binary framing: msgpack, cbor, binc, simple, bson, gob and sereal.
+// They declare a length of 2^32-1 (or 2^31-1 for bson, whose lengths are int32),
+// and each checker of that format decodes them into a typed value and into an interface{}
+// (a struct and a map[string]interface{} for bson, as its top-level value is a document).
+//
+// A decoder which trusts the length may allocate gigabytes, and be OOM-killed.
+// So the decodes run in a child process (see benchChild), whose address space may only grow by -bbl MB.
+// The child reports the bytes allocated by each decode; if it dies, the decode it was running
+// is reported as oom (with the allocation which failed) or crash.
+// codec limits its initial allocations as configured by -tx (DecodeOptions.MaxInitLen).
+//
+// Each decode is reported as (see benchOutcome):
+//   - error:    an error was returned (the expected result)
+//   - accepted: no error i.e. garbage, as the input is truncated
+//   - panic:    the decoder panicked (recovered)
+//   - oom:      the child ran out of memory (the decoder tried to allocate beyond -bbl)
+//   - crash:    the child died for another reason e.g. stack overflow
+//   - hang:     the decode (or the child) did not finish within benchBombTimeout
+//
+// Only codec failures (other than error) fail the test; others are listed after the table.
+//
+// Sample way to run:
+//    go test -tags x -run BenchAllocBomb -v
+//    go test -tags x -run BenchAllocBomb -v -tx 64 -bbl 256
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type benchBombKind uint8
+
+const (
+	benchBombArray benchBombKind = iota
+	benchBombMap
+	benchBombString
+	benchBombBytes
+	benchBombNumKinds
+)
+
+func (x benchBombKind) String() string {
+	switch x {
+	case benchBombArray:
+		return "array"
+	case benchBombMap:
+		return "map"
+	case benchBombString:
+		return "string"
+	case benchBombBytes:
+		return "bytes"
+	}
+	return "unknown"
+}
+
+// newTyped returns a new value to decode a bomb of this kind into.
+func (x benchBombKind) newTyped() interface{} {
+	switch x {
+	case benchBombArray:
+		return new([]int64)
+	case benchBombMap:
+		return new(map[string]int64)
+	case benchBombString:
+		return new(string)
+	}
+	return new([]byte)
+}
+
+const (
+	benchBombLen     = 1<<32 - 1 // declared length (bson: 1<<31 - 1)
+	benchBombTimeout = 60 * time.Second
+	benchBombRestart = 1 << 20 // re-run the child after a decode which allocated this many bytes
+)
+
+var benchBombChild = benchChild{test: "TestBenchAllocBomb", env: "CODEC_BENCH_ALLOC_BOMB", timeout: benchBombTimeout}
+
+// benchBombDoc is decoded into from bson, whose top-level value must be a document.
+// Each bomb is the value of the field for its kind (named after the lower-cased field name).
+type benchBombDoc struct {
+	A []int64
+	M map[string]int64
+	S string
+	B []byte
+}
+
+// benchBombInputs are the functions which craft the bombs in each framing.
+var benchBombInputs = map[benchFormat]func(k benchBombKind) []byte{
+	benchFormatMsgpack: func(k benchBombKind) []byte {
+		return benchBombLen32([...]byte{0xdd, 0xdf, 0xdb, 0xc6}[k], 0x01)
+	},
+	benchFormatCbor: func(k benchBombKind) []byte {
+		return benchBombLen32([...]byte{0x9a, 0xba, 0x7a, 0x5a}[k], 0x01)
+	},
+	benchFormatBinc: func(k benchBombKind) []byte {
+		// descriptor is vd<<4 | vs, where vs=2 denotes a 4-byte length
+		return benchBombLen32([...]byte{0x62, 0x72, 0x42, 0x52}[k], 0x01)
+	},
+	benchFormatSimple: func(k benchBombKind) []byte {
+		// descriptor is the base for the kind + 3, which denotes a 4-byte length
+		return benchBombLen32([...]byte{232 + 3, 240 + 3, 216 + 3, 224 + 3}[k], 0x01)
+	},
+	benchFormatBson: func(k benchBombKind) []byte {
+		var e []byte
+		switch k {
+		case benchBombArray, benchBombMap: // embedded document: int32 length, elements, 0
+			e = binary.LittleEndian.AppendUint32([]byte{[...]byte{0x04, 0x03}[k], [...]byte{'a', 'm'}[k], 0}, 1<<31-1)
+			e = append(e, 0)
+		case benchBombString: // int32 length, bytes (including a trailing 0)
+			e = binary.LittleEndian.AppendUint32([]byte{0x02, 's', 0}, 1<<31-1)
+			e = append(e, 'x', 0)
+		case benchBombBytes: // int32 length, subtype, bytes
+			e = binary.LittleEndian.AppendUint32([]byte{0x05, 'b', 0}, 1<<31-1)
+			e = append(e, 0, 'x')
+		}
+		doc := binary.LittleEndian.AppendUint32(nil, uint32(4+len(e)+1))
+		return append(append(doc, e...), 0)
+	},
+	benchFormatGob: benchBombGob,
+	benchFormatSereal: func(k benchBombKind) []byte {
+		// header: magic, version 3, empty header suffix; then the tag and a varint length
+		bs := append([]byte("=\xf3rl\x03\x00"), [...]byte{0x2b, 0x2a, 0x27, 0x26}[k])
+		return append(binary.AppendUvarint(bs, benchBombLen), 0x01)
+	},
+}
+
+// benchBombLen32 returns the descriptor, followed by benchBombLen as a big-endian uint32, and the payload.
+func benchBombLen32(desc byte, payload ...byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{desc}, benchBombLen), payload...)
+}
+
+// benchBombGob returns a gob stream with the type definitions needed for a value of this kind,
+// followed by a value which declares benchBombLen elements (or bytes).
+//
+// It is crafted from the stream of a real value, whose last message is the value:
+// its length, type id, 0 (as it is not a struct), then the length of the value and its elements.
+func benchBombGob(k benchBombKind) []byte {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode([...]interface{}{[]int64{1}, map[string]int64{"a": 1}, "a", []byte("a")}[k]); err != nil {
+		panic(err)
+	}
+	bs := buf.Bytes()
+	var last int
+	for i := 0; i < len(bs); {
+		last = i
+		n, w := benchBombGobUint(bs[i:])
+		i += w + int(n)
+	}
+	msg := bs[last:]
+	_, w := benchBombGobUint(msg) // message length
+	_, w2 := benchBombGobUint(msg[w:])
+	value := append(append([]byte(nil), msg[w:w+w2]...), 0) // type id, 0
+	value = append(benchBombGobAppendUint(value, benchBombLen), 0x01)
+	return append(benchBombGobAppendUint(bs[:last:last], uint64(len(value))), value...)
+}
+
+// benchBombGobUint decodes a gob unsigned integer, returning it and the number of bytes read.
+func benchBombGobUint(bs []byte) (v uint64, n int) {
+	if bs[0] < 0x80 {
+		return uint64(bs[0]), 1
+	}
+	n = int(-int8(bs[0]))
+	for _, c := range bs[1 : 1+n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, 1 + n
+}
+
+// benchBombGobAppendUint appends a gob unsigned integer: one byte if < 128,
+// else the negated byte count followed by the big-endian bytes.
+func benchBombGobAppendUint(bs []byte, v uint64) []byte {
+	if v < 0x80 {
+		return append(bs, byte(v))
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	i := 0
+	for b[i] == 0 {
+		i++
+	}
+	return append(append(bs, byte(-int8(8-i))), b[i:]...)
+}
+
+// benchBombDecode is a decode run in the child process.
+type benchBombDecode struct {
+	bc     *benchChecker
+	kind   benchBombKind
+	typed  bool // decode into a typed value (else an interface{})
+	status benchOutcome
+	alloc  uint64 // bytes allocated (if oom, the bytes in use and which failed to allocate)
+	detail string
+}
+
+func (x *benchBombDecode) id() string {
+	t := "intf"
+	if x.typed {
+		t = "typed"
+	}
+	return x.bc.name + "/" + x.kind.String() + "/" + t
+}
+
+func (x *benchBombDecode) target() interface{} {
+	switch {
+	case x.bc.format == benchFormatBson && x.typed:
+		return new(benchBombDoc)
+	case x.bc.format == benchFormatBson:
+		return new(map[string]interface{})
+	case x.typed:
+		return x.kind.newTyped()
+	}
+	return new(interface{})
+}
+
+// run decodes the bomb, measuring the bytes allocated.
+func (x *benchBombDecode) run() {
+	bs := benchBombInputs[x.bc.format](x.kind)
+	s := [1]metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(s[:])
+	before := s[0].Value.Uint64()
+	v := x.target()
+	decfn := benchRobustDecodeFn(x.bc)
+	x.status, _, x.detail = benchGuard(benchBombTimeout, func() error { return decfn(bs, v) })
+	metrics.Read(s[:])
+	x.alloc = s[0].Value.Uint64() - before
+	if x.status == benchOutcomeAccepted {
+		x.detail = fmt.Sprintf("decoded: %v", benchInteropTrim(fmt.Sprintf("%v", v)))
+	}
+}
+
+func benchBombDecodes() (v []*benchBombDecode) {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool {
+		return benchBombInputs[bc.format] != nil && bc.decodefn != nil
+	}) {
+		for k := benchBombKind(0); k < benchBombNumKinds; k++ {
+			v = append(v, &benchBombDecode{bc: bc, kind: k, typed: true})
+			if bc.format != benchFormatGob { // gob decodes into an interface{} only values of registered types
+				v = append(v, &benchBombDecode{bc: bc, kind: k})
+			}
+		}
+	}
+	return
+}
+
+func TestBenchAllocBomb(t *testing.T) {
+	if ids := benchBombChild.ids(); ids != nil {
+		benchBombRunChild(t, ids)
+		return
+	}
+	decodes := benchBombDecodes()
+	byID := make(map[string]*benchBombDecode, len(decodes))
+	var ids []string
+	for _, x := range decodes {
+		byID[x.id()] = x
+		ids = append(ids, x.id())
+	}
+	err := benchBombChild.runAll(ids, func(id string, o benchOutcome, result string) {
+		x := byID[id]
+		x.status = o
+		alloc, detail, _ := strings.Cut(result, "\t")
+		x.alloc, _ = strconv.ParseUint(alloc, 10, 64)
+		x.detail = detail
+	}, func(id string, r *benchChildRun) {
+		byID[id].died(r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := benchNewOutcomeTable()
+	fmt.Fprintf(tw, "checker\tinput\t")
+	for k := benchBombKind(0); k < benchBombNumKinds; k++ {
+		fmt.Fprintf(tw, "%s\t%s (intf)\t", k, k)
+	}
+	fmt.Fprintln(tw)
+	var last *benchChecker
+	for _, x := range decodes {
+		if x.bc != last {
+			if last != nil {
+				fmt.Fprintln(tw)
+			}
+			last = x.bc
+			fmt.Fprintf(tw, "%s\t%s\t", x.bc.name, benchBombInputSizes(x.bc.format))
+		}
+		fmt.Fprintf(tw, "%s %s\t", x.status, benchBombSize(x.alloc))
+		if x.bc.format == benchFormatGob && x.typed {
+			fmt.Fprintf(tw, "-\t")
+		}
+		if x.status == benchOutcomeError {
+			continue
+		}
+		tw.note("%s: %s: %s", x.id(), x.status, benchInteropTrim(x.detail))
+		if x.bc.group == benchGroupCodec {
+			t.Errorf("%s: %s: %s", x.id(), x.status, x.detail)
+		}
+	}
+	fmt.Fprintln(tw)
+	tw.flush()
+}
+
+// benchBombInputSizes returns the range of sizes of the bombs in a format e.g. 6B or 20-23B.
+func benchBombInputSizes(f benchFormat) string {
+	lo, hi := -1, 0
+	for k := benchBombKind(0); k < benchBombNumKinds; k++ {
+		n := len(benchBombInputs[f](k))
+		if lo < 0 || n < lo {
+			lo = n
+		}
+		hi = max(hi, n)
+	}
+	if lo == hi {
+		return strconv.Itoa(lo) + "B"
+	}
+	return strconv.Itoa(lo) + "-" + strconv.Itoa(hi) + "B"
+}
+
+// benchBombSize formats a number of bytes e.g. 512B, 1.5K, 32.0G.
+func benchBombSize(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return strconv.FormatUint(n, 10) + "B"
+	}
+	f, i := float64(n)/1024, 0
+	for ; f >= 1024 && i < len(units)-1; i++ {
+		f /= 1024
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64) + units[i:i+1]
+}
+
+var benchBombOOMRe = regexp.MustCompile(`cannot allocate (\d+)-byte block \((\d+) in use\)`)
+
+// died records the decode as the one which killed the child: oom (with the allocation which failed),
+// hang or crash.
+func (x *benchBombDecode) died(r *benchChildRun) {
+	x.status, x.detail = r.outcome, r.detail
+	// report the bytes in use, along with the allocation which failed
+	if m := benchBombOOMRe.FindStringSubmatch(r.out); m != nil && r.outcome == benchOutcomeOOM {
+		n, _ := strconv.ParseUint(m[1], 10, 64)
+		inuse, _ := strconv.ParseUint(m[2], 10, 64)
+		x.alloc, x.detail = n+inuse, m[0]
+	}
+}
+
+// benchBombRunChild runs the decodes in the child process, writing their results to stdout.
+// It stops early (for the parent to re-run it) after a decode which allocated a lot,
+// as its address space does not shrink, leaving less for the next decodes.
+func benchBombRunChild(t *testing.T, ids []string) {
+	byID := make(map[string]*benchBombDecode)
+	for _, x := range benchBombDecodes() {
+		byID[x.id()] = x
+	}
+	if err := benchBombLimitMemory(uint64(testv.BenchmarkBombLimitMB) << 20); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		x := byID[id]
+		if x == nil {
+			t.Fatalf("unknown decode: %s", id)
+		}
+		benchBombChild.start(id)
+		x.run()
+		benchBombChild.result(id, x.status, fmt.Sprintf("%d\t%s", x.alloc, x.detail))
+		if x.alloc > benchBombRestart {
+			return
+		}
+		runtime.GC()
+	}
+}
+
+// benchBombLimitMemory limits the address space of this process to grow by at most n bytes,
+// so an allocation beyond that fails (fatally) instead of getting the process OOM-killed.
+func benchBombLimitMemory(n uint64) error {
+	bs, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return err
+	}
+	f := strings.Fields(string(bs))
+	if len(f) == 0 {
+		return errors.New("cannot read process size from /proc/self/statm")
+	}
+	pages, err := strconv.ParseUint(f[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	lim := pages*uint64(os.Getpagesize()) + n
+	return syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: lim, Max: lim})
+}
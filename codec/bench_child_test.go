@@ -0,0 +1,254 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file runs the cases of a test which may fail badly, and reports their outcomes (see benchOutcome).
+//
+// benchGuard runs a case in a new goroutine, recovering a panic and giving up on a hang.
+//
+// benchChild runs the cases in a child process (the test binary, re-run for just that test),
+// for cases which may kill the process e.g. by running out of memory or overflowing the stack,
+// which cannot be recovered from.
+// The child is given the ids of the cases to run in an environment variable.
+// It writes "child\t<id>\tstart" to stdout before running each, and "child\t<id>\t<outcome>\t<result>" after.
+// If the child dies, the case which started but did not report a result is the one which killed it,
+// and the child is re-run for the cases it did not get to.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// benchOutcome is the outcome of a case. The first are common to all tests;
+// the others are specific to a test, which classifies its cases further.
+type benchOutcome uint8
+
+const (
+	benchOutcomeError    benchOutcome = iota // an error was returned
+	benchOutcomeAccepted                     // no error was returned
+	benchOutcomePanic                        // panicked (recovered)
+	benchOutcomeHang                         // did not return (or the child did not finish) within the timeout
+	benchOutcomeCrash                        // the child died e.g. of a fatal error
+
+	benchOutcomeOther    // TestBenchIOErrors: an error was returned, but it does not wrap the injected one
+	benchOutcomeGarbage  // TestBenchRobustness: no error, but the value does not round-trip
+	benchOutcomeOOM      // TestBenchAllocBomb: the child ran out of memory
+	benchOutcomeOverflow // TestBenchDeepNesting: the goroutine stack of the child exceeded its limit
+	benchOutcomeNum
+)
+
+func (x benchOutcome) String() string {
+	switch x {
+	case benchOutcomeError:
+		return "error"
+	case benchOutcomeAccepted:
+		return "accepted"
+	case benchOutcomePanic:
+		return "panic"
+	case benchOutcomeHang:
+		return "hang"
+	case benchOutcomeCrash:
+		return "crash"
+	case benchOutcomeOther:
+		return "other"
+	case benchOutcomeGarbage:
+		return "garbage"
+	case benchOutcomeOOM:
+		return "oom"
+	case benchOutcomeOverflow:
+		return "overflow"
+	}
+	return "unknown"
+}
+
+// benchOutcomeTable is a table of outcomes, printed to stdout,
+// with the details of the outcomes worth a note logged after it.
+type benchOutcomeTable struct {
+	*tabwriter.Writer
+	details []string
+}
+
+func benchNewOutcomeTable() *benchOutcomeTable {
+	return &benchOutcomeTable{Writer: tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)}
+}
+
+// note adds a detail, to be logged after the table.
+func (x *benchOutcomeTable) note(format string, args ...interface{}) {
+	x.details = append(x.details, fmt.Sprintf(format, args...))
+}
+
+// flush prints the table, then logs the details.
+func (x *benchOutcomeTable) flush() {
+	x.Flush()
+	for _, s := range x.details {
+		benchOnePassLogf("\t%s", s)
+	}
+}
+
+// benchGuard runs fn in a new goroutine, returning its outcome: error (with the error), accepted,
+// panic or hang (with the panic, or timeout, as the detail). After a hang, fn is left running.
+func benchGuard(timeout time.Duration, fn func() error) (o benchOutcome, err error, detail string) {
+	type result struct {
+		err error
+		r   interface{}
+	}
+	ch := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				ch <- result{r: r}
+			}
+		}()
+		ch <- result{err: fn()}
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case res := <-ch:
+		switch {
+		case res.r != nil:
+			return benchOutcomePanic, nil, fmt.Sprintf("panic: %v", res.r)
+		case res.err != nil:
+			return benchOutcomeError, res.err, res.err.Error()
+		}
+		return benchOutcomeAccepted, nil, ""
+	case <-t.C:
+		return benchOutcomeHang, nil, fmt.Sprintf("no return after %v", timeout)
+	}
+}
+
+// benchChild describes how to run the cases of a test in a child process.
+type benchChild struct {
+	test    string        // name of the test function
+	env     string        // environment variable with the ids of the cases to run (set in the child)
+	timeout time.Duration // for each run of the child
+}
+
+// benchChildRun is the outcome of a run of the child.
+type benchChildRun struct {
+	died    string       // id of the case which started, but did not report a result (if any)
+	outcome benchOutcome // of the case which died: hang, oom, overflow or crash
+	detail  string       // of the outcome e.g. the fatal error
+	out     string       // stderr, then stdout
+	err     error        // from waiting for the child
+}
+
+// crashReason returns the first line of the fatal error or panic which killed the child, else its exit error.
+func (x *benchChildRun) crashReason() string {
+	for _, s := range strings.Split(x.out, "\n") {
+		if strings.HasPrefix(s, "fatal error:") || strings.HasPrefix(s, "panic:") || strings.HasPrefix(s, "runtime:") {
+			return s
+		}
+	}
+	return fmt.Sprintf("%v", x.err)
+}
+
+// ids returns the ids of the cases to run, if in the child (else nil).
+func (x *benchChild) ids() []string {
+	if s := os.Getenv(x.env); s != "" {
+		return strings.Split(s, ",")
+	}
+	return nil
+}
+
+// start is called in the child, before running a case.
+func (x *benchChild) start(id string) {
+	fmt.Printf("child\t%s\tstart\n", id)
+}
+
+// result is called in the child, after running a case, with its outcome and the rest of its result.
+func (x *benchChild) result(id string, o benchOutcome, result string) {
+	fmt.Printf("child\t%s\t%d\t%s\n", id, o, strings.ReplaceAll(result, "\n", " "))
+}
+
+// runAll runs the child until it has run all the cases, calling done with the outcome and rest of the result
+// of each case, and died with each case which killed the child.
+func (x *benchChild) runAll(ids []string, done func(id string, o benchOutcome, result string), died func(id string, r *benchChildRun)) error {
+	for len(ids) != 0 {
+		r, rest, err := x.run(ids, done)
+		if err != nil {
+			return err
+		}
+		if r.died != "" {
+			died(r.died, &r)
+		}
+		ids = rest
+	}
+	return nil
+}
+
+// run runs the cases in a child process, calling done with the result of each,
+// and returns the cases which it did not get to (as it died).
+func (x *benchChild) run(ids []string, done func(id string, o benchOutcome, result string)) (r benchChildRun, rest []string, err error) {
+	// pass on the flags set (other than the testing flags), so the child is configured the same
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "test.") {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	args = append(args, "-test.run=^"+x.test+"$", "-test.v")
+	ctx, cancel := context.WithTimeout(context.Background(), x.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], args...)
+	cmd.Env = append(os.Environ(), x.env+"="+strings.Join(ids, ","))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, waitErr := cmd.Output()
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	finished := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(stdout))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		f := strings.SplitN(sc.Text(), "\t", 3)
+		if len(f) != 3 || f[0] != "child" || !known[f[1]] {
+			continue
+		}
+		if f[2] == "start" {
+			r.died = f[1]
+			continue
+		}
+		o, result, _ := strings.Cut(f[2], "\t")
+		n, _ := strconv.Atoi(o)
+		done(f[1], benchOutcome(n), result)
+		finished[f[1]], r.died = true, ""
+	}
+	if r.died != "" {
+		finished[r.died] = true
+		r.out = stderr.String() + string(stdout)
+		r.err = waitErr
+		switch {
+		case ctx.Err() != nil:
+			r.outcome, r.detail = benchOutcomeHang, fmt.Sprintf("no result after %v", x.timeout)
+		case strings.Contains(r.out, "out of memory"):
+			r.outcome, r.detail = benchOutcomeOOM, r.crashReason()
+		case strings.Contains(r.out, "stack exceeds"):
+			r.outcome, r.detail = benchOutcomeOverflow, r.crashReason()
+		default:
+			r.outcome, r.detail = benchOutcomeCrash, r.crashReason()
+		}
+	} else if len(finished) == 0 {
+		return r, nil, fmt.Errorf("child ran none of the cases: %v\n%s", waitErr, stderr.Bytes())
+	}
+	for _, id := range ids {
+		if !finished[id] {
+			rest = append(rest, id)
+		}
+	}
+	return
+}
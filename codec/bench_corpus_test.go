@@ -0,0 +1,532 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file adds workloads shaped like the well-known json corpora:
+//    - twitter:      twitter.json (search results: statuses with nested users and entities)
+//    - canada:       canada.json (GeoJSON polygons: a large number of float arrays)
+//    - citm:         citm_catalog.json (event catalog: maps keyed by ids, and many small records)
+//
+// The documents are produced by a deterministic generator (fixed seed),
+// so they are the same across runs and machines, and nothing needs to be downloaded.
+// Their shapes and element counts follow the originals
+// (100 statuses, a polygon with 480 rings, 184 events and 243 performances).
+//
+// Sample way to run (comparing the json libraries):
+//    go test -tags x -bench '__(En|De)code/(Json|Std_Json|JsonV2|JsonIter|GoccyJson)/' -bw twitter,canada,citm -benchmem
+//    go test -tags "alltests x" -bench CodecJsonCorpusSuite -benchmem
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const benchCorpusSeed = 20140101
+
+func init() {
+	benchWorkloads = append(benchWorkloads,
+		&benchWorkload{
+			name:     "twitter",
+			desc:     "twitter.json: search results with nested users, entities and null fields",
+			populate: benchPopulateTwitter,
+			caps: func(depth int) benchCap {
+				return benchCapNilPtrs | benchCapNilVsEmpty | benchCapScalarPtrs | benchCapPtrToZero
+			},
+			norandom: true,
+		},
+		&benchWorkload{
+			name:     "canada",
+			desc:     "canada.json: GeoJSON polygons with many float coordinates",
+			populate: benchPopulateCanada,
+			caps:     func(depth int) benchCap { return benchCapMaps | benchCapNestedSlices },
+			norandom: true,
+		},
+		&benchWorkload{
+			name:     "citm",
+			desc:     "citm_catalog.json: event catalog with maps keyed by ids and many small records",
+			populate: benchPopulateCitm,
+			caps: func(depth int) benchCap {
+				return benchCapMaps | benchCapNilPtrs | benchCapNilVsEmpty | benchCapScalarPtrs
+			},
+			norandom: true,
+		},
+	)
+}
+
+// benchCorpusNames are the workloads in this file.
+var benchCorpusNames = []string{"twitter", "canada", "citm"}
+
+var benchCorpusWords = []string{
+	"the", "go", "codec", "fast", "json", "data", "encode", "decode", "stream", "value",
+	"release", "today", "new", "people", "world", "great", "morning", "coffee", "music", "game",
+	"東京", "今日", "ありがとう", "日本", "天気", "ラーメン", "café", "naïve", "😀", "🎉",
+}
+
+// benchCorpusText returns a sentence of n words.
+func benchCorpusText(r *rand.Rand, n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(benchCorpusWords[r.Intn(len(benchCorpusWords))])
+	}
+	return sb.String()
+}
+
+func benchCorpusName(r *rand.Rand) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	var b [10]byte
+	n := 4 + r.Intn(len(b)-4)
+	for i := 0; i < n; i++ {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b[:n])
+}
+
+func benchCorpusStringPtr(r *rand.Rand, s string) *string {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+	return &s
+}
+
+// ---- twitter ----
+
+type benchTwitter struct {
+	Statuses       []benchTwitterStatus       `json:"statuses"`
+	SearchMetadata benchTwitterSearchMetadata `json:"search_metadata"`
+}
+
+type benchTwitterSearchMetadata struct {
+	CompletedIn float64 `json:"completed_in"`
+	MaxID       int64   `json:"max_id"`
+	MaxIDStr    string  `json:"max_id_str"`
+	NextResults string  `json:"next_results"`
+	Query       string  `json:"query"`
+	RefreshURL  string  `json:"refresh_url"`
+	Count       int     `json:"count"`
+	SinceID     int64   `json:"since_id"`
+	SinceIDStr  string  `json:"since_id_str"`
+}
+
+type benchTwitterStatus struct {
+	Metadata             benchTwitterMetadata `json:"metadata"`
+	CreatedAt            string               `json:"created_at"`
+	ID                   int64                `json:"id"`
+	IDStr                string               `json:"id_str"`
+	Text                 string               `json:"text"`
+	Source               string               `json:"source"`
+	Truncated            bool                 `json:"truncated"`
+	InReplyToStatusID    *int64               `json:"in_reply_to_status_id"`
+	InReplyToStatusIDStr *string              `json:"in_reply_to_status_id_str"`
+	InReplyToUserID      *int64               `json:"in_reply_to_user_id"`
+	InReplyToUserIDStr   *string              `json:"in_reply_to_user_id_str"`
+	InReplyToScreenName  *string              `json:"in_reply_to_screen_name"`
+	User                 benchTwitterUser     `json:"user"`
+	RetweetCount         int                  `json:"retweet_count"`
+	FavoriteCount        int                  `json:"favorite_count"`
+	Entities             benchTwitterEntities `json:"entities"`
+	Favorited            bool                 `json:"favorited"`
+	Retweeted            bool                 `json:"retweeted"`
+	PossiblySensitive    *bool                `json:"possibly_sensitive"`
+	Lang                 string               `json:"lang"`
+}
+
+type benchTwitterMetadata struct {
+	ResultType      string `json:"result_type"`
+	IsoLanguageCode string `json:"iso_language_code"`
+}
+
+type benchTwitterUser struct {
+	ID                             int64                    `json:"id"`
+	IDStr                          string                   `json:"id_str"`
+	Name                           string                   `json:"name"`
+	ScreenName                     string                   `json:"screen_name"`
+	Location                       string                   `json:"location"`
+	Description                    string                   `json:"description"`
+	URL                            *string                  `json:"url"`
+	Entities                       benchTwitterUserEntities `json:"entities"`
+	Protected                      bool                     `json:"protected"`
+	FollowersCount                 int                      `json:"followers_count"`
+	FriendsCount                   int                      `json:"friends_count"`
+	ListedCount                    int                      `json:"listed_count"`
+	CreatedAt                      string                   `json:"created_at"`
+	FavouritesCount                int                      `json:"favourites_count"`
+	UtcOffset                      *int                     `json:"utc_offset"`
+	TimeZone                       *string                  `json:"time_zone"`
+	GeoEnabled                     bool                     `json:"geo_enabled"`
+	Verified                       bool                     `json:"verified"`
+	StatusesCount                  int                      `json:"statuses_count"`
+	Lang                           string                   `json:"lang"`
+	ProfileBackgroundColor         string                   `json:"profile_background_color"`
+	ProfileBackgroundImageURL      string                   `json:"profile_background_image_url"`
+	ProfileBackgroundImageURLHTTPS string                   `json:"profile_background_image_url_https"`
+	ProfileImageURL                string                   `json:"profile_image_url"`
+	ProfileImageURLHTTPS           string                   `json:"profile_image_url_https"`
+	ProfileLinkColor               string                   `json:"profile_link_color"`
+	ProfileTextColor               string                   `json:"profile_text_color"`
+	ProfileUseBackgroundImage      bool                     `json:"profile_use_background_image"`
+	DefaultProfile                 bool                     `json:"default_profile"`
+	Following                      bool                     `json:"following"`
+	Notifications                  bool                     `json:"notifications"`
+}
+
+type benchTwitterUserEntities struct {
+	Description benchTwitterURLs `json:"description"`
+}
+
+type benchTwitterURLs struct {
+	Urls []benchTwitterURL `json:"urls"`
+}
+
+type benchTwitterEntities struct {
+	Hashtags     []benchTwitterHashtag     `json:"hashtags"`
+	Symbols      []benchTwitterHashtag     `json:"symbols"`
+	Urls         []benchTwitterURL         `json:"urls"`
+	UserMentions []benchTwitterUserMention `json:"user_mentions"`
+}
+
+type benchTwitterHashtag struct {
+	Text    string `json:"text"`
+	Indices []int  `json:"indices"`
+}
+
+type benchTwitterURL struct {
+	URL         string `json:"url"`
+	ExpandedURL string `json:"expanded_url"`
+	DisplayURL  string `json:"display_url"`
+	Indices     []int  `json:"indices"`
+}
+
+type benchTwitterUserMention struct {
+	ScreenName string `json:"screen_name"`
+	Name       string `json:"name"`
+	ID         int64  `json:"id"`
+	IDStr      string `json:"id_str"`
+	Indices    []int  `json:"indices"`
+}
+
+func benchTwitterDate(r *rand.Rand) string {
+	days := [...]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	months := [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+	return days[r.Intn(7)] + " " + months[r.Intn(12)] + " " + strconv.Itoa(10+r.Intn(18)) +
+		" 0" + strconv.Itoa(r.Intn(10)) + ":" + strconv.Itoa(10+r.Intn(50)) + ":" + strconv.Itoa(10+r.Intn(50)) +
+		" +0000 20" + strconv.Itoa(10+r.Intn(5))
+}
+
+func benchTwitterIndices(r *rand.Rand) []int {
+	i := r.Intn(100)
+	return []int{i, i + 1 + r.Intn(20)}
+}
+
+func benchNewTwitterURL(r *rand.Rand) benchTwitterURL {
+	s := benchCorpusName(r)
+	return benchTwitterURL{
+		URL:         "http://t.co/" + s,
+		ExpandedURL: "http://www.example.com/" + s + "/" + strconv.Itoa(r.Intn(100000)),
+		DisplayURL:  "example.com/" + s,
+		Indices:     benchTwitterIndices(r),
+	}
+}
+
+func benchNewTwitterUser(r *rand.Rand) (v benchTwitterUser) {
+	id := int64(r.Int31())
+	name := benchCorpusName(r)
+	v = benchTwitterUser{
+		ID:                             id,
+		IDStr:                          strconv.FormatInt(id, 10),
+		Name:                           benchCorpusText(r, 2),
+		ScreenName:                     name,
+		Location:                       benchCorpusText(r, 1),
+		Description:                    benchCorpusText(r, 5+r.Intn(15)),
+		URL:                            benchCorpusStringPtr(r, "http://t.co/"+name),
+		Protected:                      r.Intn(10) == 0,
+		FollowersCount:                 r.Intn(10000),
+		FriendsCount:                   r.Intn(2000),
+		ListedCount:                    r.Intn(100),
+		CreatedAt:                      benchTwitterDate(r),
+		FavouritesCount:                r.Intn(5000),
+		TimeZone:                       benchCorpusStringPtr(r, "Tokyo"),
+		GeoEnabled:                     r.Intn(2) == 0,
+		Verified:                       r.Intn(20) == 0,
+		StatusesCount:                  r.Intn(50000),
+		Lang:                           "ja",
+		ProfileBackgroundColor:         "C0DEED",
+		ProfileBackgroundImageURL:      "http://abs.twimg.com/images/themes/theme1/bg.png",
+		ProfileBackgroundImageURLHTTPS: "https://abs.twimg.com/images/themes/theme1/bg.png",
+		ProfileImageURL:                "http://pbs.twimg.com/profile_images/" + name + "_normal.jpeg",
+		ProfileImageURLHTTPS:           "https://pbs.twimg.com/profile_images/" + name + "_normal.jpeg",
+		ProfileLinkColor:               "0084B4",
+		ProfileTextColor:               "333333",
+		ProfileUseBackgroundImage:      true,
+		DefaultProfile:                 r.Intn(2) == 0,
+	}
+	if v.TimeZone != nil {
+		off := 32400
+		v.UtcOffset = &off
+	}
+	for i, n := 0, r.Intn(2); i < n; i++ {
+		v.Entities.Description.Urls = append(v.Entities.Description.Urls, benchNewTwitterURL(r))
+	}
+	return
+}
+
+func benchNewTwitterStatus(r *rand.Rand) (v benchTwitterStatus) {
+	id := 505874924095815681 + r.Int63n(1000000)
+	v = benchTwitterStatus{
+		Metadata:      benchTwitterMetadata{ResultType: "recent", IsoLanguageCode: "ja"},
+		CreatedAt:     benchTwitterDate(r),
+		ID:            id,
+		IDStr:         strconv.FormatInt(id, 10),
+		Text:          benchCorpusText(r, 5+r.Intn(25)),
+		Source:        `<a href="http://twitter.com/download/iphone" rel="nofollow">Twitter for iPhone</a>`,
+		User:          benchNewTwitterUser(r),
+		RetweetCount:  r.Intn(100),
+		FavoriteCount: r.Intn(100),
+		Lang:          "ja",
+	}
+	if r.Intn(4) == 0 {
+		rid, ruid, rname := id-1-r.Int63n(1000), int64(r.Int31()), benchCorpusName(r)
+		rids, ruids := strconv.FormatInt(rid, 10), strconv.FormatInt(ruid, 10)
+		v.InReplyToStatusID, v.InReplyToStatusIDStr = &rid, &rids
+		v.InReplyToUserID, v.InReplyToUserIDStr = &ruid, &ruids
+		v.InReplyToScreenName = &rname
+	}
+	if r.Intn(3) == 0 {
+		b := false
+		v.PossiblySensitive = &b
+	}
+	// like the original, empty entities are encoded as [] (not null)
+	v.Entities = benchTwitterEntities{
+		Hashtags:     []benchTwitterHashtag{},
+		Symbols:      []benchTwitterHashtag{},
+		Urls:         []benchTwitterURL{},
+		UserMentions: []benchTwitterUserMention{},
+	}
+	for i, n := 0, r.Intn(3); i < n; i++ {
+		v.Entities.Hashtags = append(v.Entities.Hashtags,
+			benchTwitterHashtag{Text: benchCorpusText(r, 1), Indices: benchTwitterIndices(r)})
+	}
+	for i, n := 0, r.Intn(2); i < n; i++ {
+		v.Entities.Urls = append(v.Entities.Urls, benchNewTwitterURL(r))
+	}
+	for i, n := 0, r.Intn(3); i < n; i++ {
+		uid := int64(r.Int31())
+		v.Entities.UserMentions = append(v.Entities.UserMentions, benchTwitterUserMention{
+			ScreenName: benchCorpusName(r),
+			Name:       benchCorpusText(r, 2),
+			ID:         uid,
+			IDStr:      strconv.FormatInt(uid, 10),
+			Indices:    benchTwitterIndices(r),
+		})
+	}
+	return
+}
+
+func benchPopulateTwitter(depth, repeat int) interface{} {
+	const n = 100
+	r := rand.New(rand.NewSource(benchCorpusSeed))
+	v := &benchTwitter{Statuses: make([]benchTwitterStatus, n)}
+	for i := range v.Statuses {
+		v.Statuses[i] = benchNewTwitterStatus(r)
+	}
+	v.SearchMetadata = benchTwitterSearchMetadata{
+		CompletedIn: 0.087,
+		MaxID:       v.Statuses[0].ID,
+		MaxIDStr:    v.Statuses[0].IDStr,
+		NextResults: "?max_id=" + v.Statuses[n-1].IDStr + "&q=%E4%B8%80&count=100&include_entities=1",
+		Query:       "%E4%B8%80",
+		RefreshURL:  "?since_id=" + v.Statuses[0].IDStr + "&q=%E4%B8%80&include_entities=1",
+		Count:       n,
+	}
+	return v
+}
+
+// ---- canada ----
+
+type benchCanada struct {
+	Type     string               `json:"type"`
+	Features []benchCanadaFeature `json:"features"`
+}
+
+type benchCanadaFeature struct {
+	Type       string              `json:"type"`
+	Properties map[string]string   `json:"properties"`
+	Geometry   benchCanadaGeometry `json:"geometry"`
+}
+
+type benchCanadaGeometry struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
+}
+
+func benchPopulateCanada(depth, repeat int) interface{} {
+	// canada.json has a single polygon with 480 rings and ~56K points.
+	const numRings = 480
+	r := rand.New(rand.NewSource(benchCorpusSeed))
+	coords := make([][][]float64, numRings)
+	for i := range coords {
+		// a random walk around a starting point, closed at the end (like a GeoJSON ring)
+		n := 4 + r.Intn(228)
+		lon, lat := -141+r.Float64()*88, 41.7+r.Float64()*41.4
+		ring := make([][]float64, n)
+		for j := 0; j < n-1; j++ {
+			ring[j] = []float64{lon, lat}
+			lon += (r.Float64() - 0.5) / 50
+			lat += (r.Float64() - 0.5) / 50
+		}
+		ring[n-1] = []float64{ring[0][0], ring[0][1]}
+		coords[i] = ring
+	}
+	return &benchCanada{
+		Type: "FeatureCollection",
+		Features: []benchCanadaFeature{{
+			Type:       "Feature",
+			Properties: map[string]string{"name": "Canada"},
+			Geometry:   benchCanadaGeometry{Type: "Polygon", Coordinates: coords},
+		}},
+	}
+}
+
+// ---- citm ----
+
+type benchCitm struct {
+	AreaNames                map[string]string         `json:"areaNames"`
+	AudienceSubCategoryNames map[string]string         `json:"audienceSubCategoryNames"`
+	BlockNames               map[string]string         `json:"blockNames"`
+	Events                   map[string]benchCitmEvent `json:"events"`
+	Performances             []benchCitmPerformance    `json:"performances"`
+	SeatCategoryNames        map[string]string         `json:"seatCategoryNames"`
+	SubTopicNames            map[string]string         `json:"subTopicNames"`
+	SubjectNames             map[string]string         `json:"subjectNames"`
+	TopicNames               map[string]string         `json:"topicNames"`
+	TopicSubTopics           map[string][]int64        `json:"topicSubTopics"`
+	VenueNames               map[string]string         `json:"venueNames"`
+}
+
+type benchCitmEvent struct {
+	Description *string `json:"description"`
+	ID          int64   `json:"id"`
+	Logo        *string `json:"logo"`
+	Name        string  `json:"name"`
+	SubTopicIds []int64 `json:"subTopicIds"`
+	SubjectCode *string `json:"subjectCode"`
+	Subtitle    *string `json:"subtitle"`
+	TopicIds    []int64 `json:"topicIds"`
+}
+
+type benchCitmPerformance struct {
+	EventID        int64                   `json:"eventId"`
+	ID             int64                   `json:"id"`
+	Logo           *string                 `json:"logo"`
+	Name           *string                 `json:"name"`
+	Prices         []benchCitmPrice        `json:"prices"`
+	SeatCategories []benchCitmSeatCategory `json:"seatCategories"`
+	SeatMapImage   *string                 `json:"seatMapImage"`
+	Start          int64                   `json:"start"`
+	VenueCode      string                  `json:"venueCode"`
+}
+
+type benchCitmPrice struct {
+	Amount                int   `json:"amount"`
+	AudienceSubCategoryID int64 `json:"audienceSubCategoryId"`
+	SeatCategoryID        int64 `json:"seatCategoryId"`
+}
+
+type benchCitmSeatCategory struct {
+	Areas          []benchCitmArea `json:"areas"`
+	SeatCategoryID int64           `json:"seatCategoryId"`
+}
+
+type benchCitmArea struct {
+	AreaID   int64   `json:"areaId"`
+	BlockIds []int64 `json:"blockIds"`
+}
+
+// benchCitmNames returns a map of n names keyed by ids (as strings), and the ids.
+func benchCitmNames(r *rand.Rand, n int, base int64) (m map[string]string, ids []int64) {
+	m = make(map[string]string, n)
+	ids = make([]int64, n)
+	for i := range ids {
+		ids[i] = base + int64(i)*13
+		m[strconv.FormatInt(ids[i], 10)] = benchCorpusText(r, 1+r.Intn(3))
+	}
+	return
+}
+
+func benchPopulateCitm(depth, repeat int) interface{} {
+	// citm_catalog.json has 184 events and 243 performances.
+	const numEvents, numPerformances = 184, 243
+	r := rand.New(rand.NewSource(benchCorpusSeed))
+	pick := func(ids []int64) int64 { return ids[r.Intn(len(ids))] }
+	v := &benchCitm{
+		// like the original, blockNames and subjectNames are empty
+		BlockNames:     map[string]string{},
+		SubjectNames:   map[string]string{},
+		Events:         make(map[string]benchCitmEvent, numEvents),
+		TopicSubTopics: make(map[string][]int64),
+	}
+	var areaIds, audienceIds, seatCategoryIds, subTopicIds, topicIds []int64
+	v.AreaNames, areaIds = benchCitmNames(r, 17, 205705993)
+	v.AudienceSubCategoryNames, audienceIds = benchCitmNames(r, 1, 337100890)
+	v.SeatCategoryNames, seatCategoryIds = benchCitmNames(r, 64, 338937235)
+	v.SubTopicNames, subTopicIds = benchCitmNames(r, 19, 337184262)
+	v.TopicNames, topicIds = benchCitmNames(r, 4, 107888604)
+	v.VenueNames = map[string]string{"PLEYEL_PLEYEL": "Salle Pleyel"}
+	for _, id := range topicIds {
+		v.TopicSubTopics[strconv.FormatInt(id, 10)] = []int64{pick(subTopicIds), pick(subTopicIds)}
+	}
+	eventIds := make([]int64, numEvents)
+	for i := range eventIds {
+		id := 138586341 + int64(i)*17
+		eventIds[i] = id
+		v.Events[strconv.FormatInt(id, 10)] = benchCitmEvent{
+			Description: benchCorpusStringPtr(r, benchCorpusText(r, 10)),
+			ID:          id,
+			Logo:        benchCorpusStringPtr(r, "/images/UE0AAAAACEKo6QAAAAZDSVRN"),
+			Name:        benchCorpusText(r, 2+r.Intn(4)),
+			SubTopicIds: []int64{pick(subTopicIds), pick(subTopicIds)},
+			TopicIds:    []int64{pick(topicIds), pick(topicIds)},
+		}
+	}
+	v.Performances = make([]benchCitmPerformance, numPerformances)
+	for i := range v.Performances {
+		p := benchCitmPerformance{
+			EventID:      pick(eventIds),
+			ID:           339887544 + int64(i)*11,
+			Logo:         benchCorpusStringPtr(r, "/images/UE0AAAAACEKo6QAAAAZDSVRN"),
+			SeatMapImage: nil,
+			Start:        1372701600000 + int64(i)*86400000,
+			VenueCode:    "PLEYEL_PLEYEL",
+		}
+		for j, n := 0, 1+r.Intn(8); j < n; j++ {
+			sc := pick(seatCategoryIds)
+			p.Prices = append(p.Prices, benchCitmPrice{
+				Amount: 9500 + r.Intn(80)*1000, AudienceSubCategoryID: pick(audienceIds), SeatCategoryID: sc})
+			c := benchCitmSeatCategory{SeatCategoryID: sc}
+			for k, m := 0, 1+r.Intn(6); k < m; k++ {
+				c.Areas = append(c.Areas, benchCitmArea{AreaID: pick(areaIds), BlockIds: []int64{}})
+			}
+			p.SeatCategories = append(p.SeatCategories, c)
+		}
+		v.Performances[i] = p
+	}
+	return v
+}
+
+func TestBenchCorpusDeterministic(t *testing.T) {
+	for _, n := range benchCorpusNames {
+		w := benchWorkloadFind(n)
+		v1 := w.populate(testv.Depth, testv.NumRepeatString)
+		v2 := w.populate(testv.Depth, testv.NumRepeatString)
+		if d := testDiff(v1, v2, false); len(d) != 0 {
+			t.Errorf("%s: generated documents differ across runs: %v", n, d)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file checks how each decoder copes with deeply nested input, and how deep values
+// grow the stack.
+//
+// TestBenchDeepNesting decodes arrays (and maps) nested -bnd levels deep (e.g. [[[...]]]),
+// in each framing, into an interface{}. A recursive decoder without a depth limit
+// eventually overflows the stack, which is fatal (it cannot be recovered),
+// so the decodes run in a child process (see benchChild). Each decode is reported as (see benchOutcome):
+//   - error:    an error was returned e.g. the depth limit was exceeded (the expected result)
+//   - accepted: no error i.e. the decoder has no depth limit below this depth
+//   - panic:    the decoder panicked (recovered)
+//   - overflow: the child died as its goroutine stack exceeded the limit (1GB by default)
+//   - crash:    the child died for another reason
+//   - hang:     the child did not finish within benchDeepTimeout
+//
+// gob, xml and xdr do not decode into an interface{} (without registered types), and neither do
+// generated libraries, so they are not checked. For bson, whose top-level value must be a document,
+// the outermost level is a document.
+//
+// codec must return its depth-limit error (see DecodeOptions.MaxDepth, 1024 by default), else the test fails.
+// TestBenchMaxDepth checks, for each codec Handle, that a MaxDepth of N allows N-1 levels but not N
+// (as codec fails when the depth reaches MaxDepth, not when it exceeds it).
+//
+// Benchmark__DeepStruc encodes and decodes TestStruc trees of each depth in -bdd, in a new goroutine
+// each time (as a server would for each request), so the time includes growing the stack from its
+// starting size. It reports the stack memory obtained from the heap by one encode (or decode)
+// as stack-bytes (from the runtime metric /memory/classes/heap/stacks:bytes, after a GC).
+// Stacks up to 16KB come from the runtime's cache of small stacks, so are mostly reported as 0.
+// Larger stacks grow by copying to one twice the size, so are reported as the sum of each size they grew through.
+//
+// Sample way to run:
+//    go test -tags x -run 'BenchDeepNesting|BenchMaxDepth' -v
+//    go test -tags x -run BenchDeepNesting -v -bnd 1025,100000
+//    go test -tags x -run XXX -bench __DeepStruc -bdd 2,4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	. "github.com/ugorji/go/codec"
+)
+
+type benchDeepKind uint8
+
+const (
+	benchDeepArray benchDeepKind = iota
+	benchDeepMap
+	benchDeepNumKinds
+)
+
+func (x benchDeepKind) String() string {
+	if x == benchDeepMap {
+		return "map"
+	}
+	return "array"
+}
+
+// container returns a container of this kind, with v as its only element (or none if v is nil).
+func (x benchDeepKind) container(v interface{}) interface{} {
+	if x == benchDeepMap {
+		if v == nil {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{"a": v}
+	}
+	if v == nil {
+		return []interface{}{}
+	}
+	return []interface{}{v}
+}
+
+const (
+	benchDeepTimeout     = 2 * time.Minute
+	benchDeepMaxDepthErr = "maximum decoding depth exceeded"
+)
+
+var benchDeepChild = benchChild{test: "TestBenchDeepNesting", env: "CODEC_BENCH_DEEP_NESTING", timeout: benchDeepTimeout}
+
+// benchDeepFormats are the formats whose nested input can be decoded into an interface{}.
+// The input is crafted by the function given, else derived from the encoder (see benchDeepInput).
+var benchDeepFormats = map[benchFormat]func(k benchDeepKind, depth int) []byte{
+	benchFormatMsgpack: nil,
+	benchFormatBinc:    nil,
+	benchFormatSimple:  nil,
+	benchFormatCbor:    nil,
+	benchFormatJson:    nil,
+	benchFormatBson:    benchDeepBson,
+	benchFormatSereal:  benchDeepSereal,
+}
+
+// benchDeepInput returns a container of this kind, nested depth levels deep, as encoded by encfn.
+//
+// An empty container E is encoded as a header (if any) and a body, and a container of E as the same header,
+// then a prefix P, the body of E, and a suffix S e.g. [] and [[]] in json.
+// So the nested input is the header, P (depth-1 times), the body of E, and S (depth-1 times).
+func benchDeepInput(encfn benchEncFn, k benchDeepKind, depth int) ([]byte, error) {
+	var enc [3][]byte // the encoding of a container nested 1, 2 and 3 levels deep
+	var v interface{}
+	for i := range enc {
+		v = k.container(v)
+		bs, err := encfn(v, nil)
+		if err != nil {
+			return nil, err
+		}
+		enc[i] = append([]byte(nil), bs...) // some encoders return bytes only valid until their next call
+	}
+	e, ee := enc[0], enc[1]
+	var h int
+	for h < len(e) && h < len(ee) && e[h] == ee[h] {
+		h++
+	}
+	// the header is at most the common prefix (which may include the start of P),
+	// and must give back the encoding of 3 levels
+	for ; h >= 0; h-- {
+		if i := strings.LastIndex(string(ee[h:]), string(e[h:])); i >= 0 {
+			p, s := ee[h:h+i], ee[h+i+len(e)-h:]
+			if string(e[:h])+string(benchDeepRepeat(p, e[h:], s, 3)) == string(enc[2]) {
+				return append(append([]byte(nil), e[:h]...), benchDeepRepeat(p, e[h:], s, depth)...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot find the nesting of %v (%x) in %v (%x)", k.container(nil), e, v, enc[2])
+}
+
+// benchDeepRepeat returns p (n-1 times), then e, then s (n-1 times).
+func benchDeepRepeat(p, e, s []byte, n int) []byte {
+	bs := make([]byte, 0, len(e)+(n-1)*(len(p)+len(s)))
+	for i := 1; i < n; i++ {
+		bs = append(bs, p...)
+	}
+	bs = append(bs, e...)
+	for i := 1; i < n; i++ {
+		bs = append(bs, s...)
+	}
+	return bs
+}
+
+// benchDeepBson returns a bson document nested depth levels deep: a document with an element
+// (named a for a document, or 0 for an array) holding the next level, down to an empty document.
+//
+// Each level is its int32 length, the element type, name and 0, the next level, and a trailing 0.
+func benchDeepBson(k benchDeepKind, depth int) []byte {
+	typ, name := byte(0x03), byte('a')
+	if k == benchDeepArray {
+		typ, name = 0x04, '0'
+	}
+	const empty, level = 5, 8 // size of an empty document, and what each level around it adds
+	bs := make([]byte, 0, empty+(depth-1)*level)
+	for i := 1; i < depth; i++ {
+		bs = binary.LittleEndian.AppendUint32(bs, uint32(empty+(depth-i)*level))
+		bs = append(bs, typ, name, 0)
+	}
+	bs = append(binary.LittleEndian.AppendUint32(bs, empty), 0)
+	for i := 1; i < depth; i++ {
+		bs = append(bs, 0)
+	}
+	return bs
+}
+
+// benchDeepSereal returns a sereal document nested depth levels deep: a header, then an array
+// (or a hash with a key a) of 1 element holding the next level, down to an empty one.
+//
+// It is crafted, as the sereal encoder writes each repeated key as a COPY of the first.
+func benchDeepSereal(k benchDeepKind, depth int) []byte {
+	// header: magic, version 3, empty header suffix
+	hdr, p, e := []byte("=\xf3rl\x03\x00"), []byte{0x2b, 0x01}, []byte{0x2b, 0x00} // ARRAY, count
+	if k == benchDeepMap {
+		p, e = []byte{0x2a, 0x01, 0x27, 0x01, 'a'}, []byte{0x2a, 0x00} // HASH, count, BINARY key a
+	}
+	return append(hdr, benchDeepRepeat(p, e, nil, depth)...)
+}
+
+// benchDeepDecode is a decode run in the child process.
+type benchDeepDecode struct {
+	bc     *benchChecker
+	kind   benchDeepKind
+	depth  int
+	status benchOutcome
+	detail string
+}
+
+func (x *benchDeepDecode) id() string {
+	return x.bc.name + "/" + x.kind.String() + "/" + strconv.Itoa(x.depth)
+}
+
+// input returns the nested input, crafted for its format, else encoded by the first checker
+// of its format (i.e. codec, for the formats it supports).
+func (x *benchDeepDecode) input() ([]byte, error) {
+	if fn := benchDeepFormats[x.bc.format]; fn != nil {
+		return fn(x.kind, x.depth), nil
+	}
+	for _, bc := range benchCheckersFor(nil) {
+		if bc.format == x.bc.format && bc.encodefn != nil {
+			return benchDeepInput(bc.encodefn, x.kind, x.depth)
+		}
+	}
+	return nil, fmt.Errorf("no encoder for %s", x.bc.format)
+}
+
+func (x *benchDeepDecode) run() {
+	bs, err := x.input()
+	if err != nil {
+		x.status, x.detail = benchOutcomeCrash, "error creating input: "+err.Error()
+		return
+	}
+	var v interface{} = new(interface{})
+	if x.bc.format == benchFormatBson {
+		v = new(map[string]interface{})
+	}
+	decfn := benchRobustDecodeFn(x.bc)
+	x.status, _, x.detail = benchGuard(benchDeepTimeout, func() error { return decfn(bs, v) })
+	if x.status == benchOutcomeAccepted {
+		x.detail = fmt.Sprintf("decoded %d bytes", len(bs))
+	}
+}
+
+func benchDeepDecodes() (v []*benchDeepDecode, err error) {
+	depths, err := benchSweepInts(testv.BenchmarkNestingDepths)
+	if err != nil {
+		return
+	}
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool {
+		_, ok := benchDeepFormats[bc.format]
+		return ok && bc.decodefn != nil && bc.group != benchGroupXGen
+	}) {
+		for _, d := range depths {
+			for k := benchDeepKind(0); k < benchDeepNumKinds; k++ {
+				v = append(v, &benchDeepDecode{bc: bc, kind: k, depth: max(d, 1)})
+			}
+		}
+	}
+	return
+}
+
+func TestBenchDeepNesting(t *testing.T) {
+	decodes, err := benchDeepDecodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]*benchDeepDecode, len(decodes))
+	var ids []string
+	for _, x := range decodes {
+		byID[x.id()] = x
+		ids = append(ids, x.id())
+	}
+	if ids := benchDeepChild.ids(); ids != nil {
+		for _, id := range ids {
+			x := byID[id]
+			if x == nil {
+				t.Fatalf("unknown decode: %s", id)
+			}
+			benchDeepChild.start(id)
+			x.run()
+			benchDeepChild.result(id, x.status, x.detail)
+			runtime.GC()
+		}
+		return
+	}
+	err = benchDeepChild.runAll(ids, func(id string, o benchOutcome, result string) {
+		byID[id].status, byID[id].detail = o, result
+	}, func(id string, r *benchChildRun) {
+		byID[id].status, byID[id].detail = r.outcome, r.detail
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := benchNewOutcomeTable()
+	fmt.Fprintf(tw, "checker\t")
+	var last *benchChecker
+	for _, x := range decodes {
+		if last != nil && x.bc != last {
+			break
+		}
+		last = x.bc
+		fmt.Fprintf(tw, "%s %d\t", x.kind, x.depth)
+	}
+	last = nil
+	for _, x := range decodes {
+		if x.bc != last {
+			last = x.bc
+			fmt.Fprintf(tw, "\n%s\t", x.bc.name)
+		}
+		fmt.Fprintf(tw, "%s\t", x.status)
+		if x.bc.group == benchGroupCodec && x.depth >= 1024 && !strings.Contains(x.detail, benchDeepMaxDepthErr) {
+			t.Errorf("%s: %s: %s", x.id(), x.status, x.detail)
+		}
+		if x.status != benchOutcomeError {
+			tw.note("%s: %s: %s", x.id(), x.status, benchInteropTrim(x.detail))
+		}
+	}
+	fmt.Fprintln(tw)
+	tw.flush()
+}
+
+func TestBenchMaxDepth(t *testing.T) {
+	limits := [...]int16{1, 64, 0, 16384, 32767} // 0 is the default (1024)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "checker\t")
+	for _, n := range limits {
+		fmt.Fprintf(tw, "MaxDepth %d\t", n)
+	}
+	fmt.Fprintln(tw)
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.group == benchGroupCodec }) {
+		fmt.Fprintf(tw, "%s\t", bc.name)
+		for _, n := range limits {
+			h := benchCodecNewHandle(bc.format, func(bh *BasicHandle) { bh.MaxDepth = n })
+			limit := int(n)
+			if limit == 0 {
+				limit = 1024
+			}
+			var failed []string
+			for k := benchDeepKind(0); k < benchDeepNumKinds; k++ {
+				for _, d := range [...]int{limit - 1, limit} {
+					if d == 0 {
+						continue
+					}
+					bs, err := benchDeepInput(benchCodecEncodeFn(h), k, d)
+					if err != nil {
+						t.Fatalf("%s: %v", bc.name, err)
+					}
+					err = testSharedCodecDecode(bs, new(interface{}), h, false)
+					if (err == nil) != (d < limit) || (err != nil && !strings.Contains(err.Error(), benchDeepMaxDepthErr)) {
+						failed = append(failed, fmt.Sprintf("%s %d", k, d))
+						t.Errorf("%s: MaxDepth %d: %s nested %d levels: error: %v", bc.name, n, k, d, err)
+					}
+				}
+			}
+			if failed == nil {
+				fmt.Fprintf(tw, "ok\t")
+			} else {
+				fmt.Fprintf(tw, "failed: %s\t", strings.Join(failed, ", "))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// benchCodecEncodeFn returns a benchEncFn which encodes with h.
+func benchCodecEncodeFn(h Handle) benchEncFn {
+	return func(v interface{}, bs []byte) ([]byte, error) {
+		return testSharedCodecEncode(v, bs, fnBenchmarkByteBuf, h, false)
+	}
+}
+
+func Benchmark__DeepStruc(b *testing.B) {
+	depths, err := benchSweepInts(testv.BenchmarkDeepStrucDepths)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func(depth int) {
+		testv.Depth = depth
+		benchInitValues()
+	}(testv.Depth)
+	for _, d := range depths {
+		testv.Depth = d
+		benchInitValues()
+		b.Run("bd"+strconv.Itoa(d), func(b *testing.B) {
+			b.Run("Encode", func(b *testing.B) {
+				for _, bc := range benchCheckersFor(nil) {
+					if bc.runnable() {
+						b.Run(bc.title, bc.benchDeepEncode)
+					}
+				}
+			})
+			b.Run("Decode", func(b *testing.B) {
+				for _, bc := range benchCheckersFor(nil) {
+					if bc.runnable() {
+						b.Run(bc.title, bc.benchDeepDecode)
+					}
+				}
+			})
+		})
+	}
+}
+
+func (x *benchChecker) benchDeepEncode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		b.ReportAllocs()
+		bs, err := x.encodefn(w.v, nil)
+		if err != nil {
+			b.Fatalf("%s: error encoding: %v", x.name, err)
+		}
+		buf := make([]byte, 0, len(bs))
+		benchDeepRun(b, func() error {
+			_, err := x.encodefn(w.v, buf)
+			return err
+		})
+		fnBenchmarkReportSize(b, len(bs), w.baseLen)
+	})
+}
+
+func (x *benchChecker) benchDeepDecode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		b.ReportAllocs()
+		bs, err := benchDecodeEncodeFn(x)(w.v, nil)
+		if err != nil {
+			b.Fatalf("%s: error encoding: %v", x.name, err)
+		}
+		bs = append([]byte(nil), bs...)
+		benchDeepRun(b, func() error { return x.decodefn(bs, w.new()) })
+		fnBenchmarkReportSize(b, len(bs), w.baseLen)
+	})
+}
+
+// benchDeepRun runs fn in a new goroutine for each iteration, and reports the stack memory
+// obtained by one run (see benchDeepStackBytes).
+func benchDeepRun(b *testing.B, fn func() error) {
+	run := func() error {
+		ch := make(chan error, 1)
+		go func() { ch <- fn() }()
+		return <-ch
+	}
+	n, err := benchDeepStackBytes(fn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fnBenchmarkRun(b, func() {
+		if err := run(); err != nil {
+			b.Fatal(err)
+		}
+	})
+	b.ReportMetric(float64(n), "stack-bytes")
+}
+
+// benchDeepStackBytes runs fn in a new goroutine, and returns the stack memory obtained from the heap
+// by the time it returns. It runs after a GC, which returns unused stack memory to the heap.
+func benchDeepStackBytes(fn func() error) (n uint64, err error) {
+	s := [1]metrics.Sample{{Name: "/memory/classes/heap/stacks:bytes"}}
+	runtime.GC()
+	metrics.Read(s[:])
+	before := s[0].Value.Uint64()
+	ch := make(chan uint64, 1)
+	go func() {
+		err = fn()
+		s := [1]metrics.Sample{{Name: "/memory/classes/heap/stacks:bytes"}}
+		metrics.Read(s[:])
+		ch <- s[0].Value.Uint64()
+	}()
+	if after := <-ch; after > before {
+		n = after - before
+	}
+	return
+}
@@ -0,0 +1,143 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file checks that each library's deterministic mode (see benchChecker.detenc) encodes
+// the same value to the same bytes every time, as needed when encoded values are hashed or signed.
+// The deterministic modes are:
+//   - codec:     Canonical set on the Handle
+//   - std-json:  always (map keys are sorted)
+//   - goccyjson: always (map keys are sorted, unless UnorderedMap)
+//   - json-iter: Config.SortMapKeys
+//   - jsonv2:    Deterministic(true)
+//   - fxcbor:    CoreDetEncOptions (RFC 8949 Core Deterministic Encoding)
+//   - v-msgpack: Encoder.SetSortMapKeys (which only sorts map[string]string, map[string]bool and map[string]interface{})
+//
+// TestBenchDeterministic encodes the teststruc workload (whose maps are iterated in random order)
+// -bdn times with each library, in its deterministic mode and its default mode, and reports
+// whether each mode gave identical bytes every time. The default mode is reported for comparison
+// (a mode which iterates maps should differ), and libraries without a deterministic mode are reported as n/a.
+// Each library must be identical in its deterministic mode, else the test fails,
+// unless it is a known issue (see benchDeterministicKnownIssues).
+//
+// Benchmark__Deterministic encodes with each library which has a deterministic mode,
+// in the default and deterministic modes, to show the cost of sorting.
+//
+// Sample way to run:
+//    go test -tags x -run BenchDeterministic -v
+//    go test -tags x -run BenchDeterministic -v -bdn 256
+//    go test -tags x -run XXX -bench __Deterministic -benchmem
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"text/tabwriter"
+)
+
+// benchDeterministicKnownIssues are the libraries whose deterministic mode is known to differ
+// for the teststruc workload, and why. They are listed, but only fail the test if they do not differ.
+var benchDeterministicKnownIssues = map[string]string{
+	// SetSortMapKeys sorts only top-level maps of a few types (see the file comment),
+	// so the nested and typed maps of TestStruc still encode in random order.
+	"v-msgpack": "Encoder.SetSortMapKeys does not sort nested or typed maps",
+}
+
+// benchDeterministicCheck encodes v n times with fn, and returns the number of distinct encodings
+// (1 if deterministic), and the size of the first one.
+func benchDeterministicCheck(fn benchEncFn, v interface{}, n int) (distinct, size int, err error) {
+	var encs [][]byte
+	for i := 0; i < n; i++ {
+		bs, err := fn(v, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+		found := false
+		for _, e := range encs {
+			if found = bytes.Equal(e, bs); found {
+				break
+			}
+		}
+		if !found {
+			encs = append(encs, append([]byte(nil), bs...))
+		}
+	}
+	return len(encs), len(encs[0]), nil
+}
+
+// benchDeterministicStatus describes the result of benchDeterministicCheck.
+func benchDeterministicStatus(distinct, n int, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case distinct == 1:
+		return "identical"
+	}
+	return fmt.Sprintf("differs (%d/%d distinct)", distinct, n)
+}
+
+func TestBenchDeterministic(t *testing.T) {
+	n := testv.BenchmarkDeterministicRuns
+	if n < 2 {
+		t.Fatalf("-bdn must be at least 2: %d", n)
+	}
+	w := benchWorkloadFind(benchWorkloadDefault)
+	var details []string
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "checker\tdeterministic\tdefault\tsize (deterministic/default)\n")
+	for _, bc := range benchCheckersFor(nil) {
+		if reason := bc.skipReason(w); reason != "" {
+			continue
+		}
+		detStatus, detSize := "n/a", "-"
+		if bc.detenc != nil {
+			distinct, size, err := benchDeterministicCheck(bc.detenc, w.v, n)
+			detStatus, detSize = benchDeterministicStatus(distinct, n, err), fmt.Sprint(size)
+			known, isKnown := benchDeterministicKnownIssues[bc.name]
+			switch {
+			case err != nil:
+				t.Errorf("%s: deterministic mode: %v", bc.name, err)
+				details = append(details, fmt.Sprintf("%s: deterministic mode: %v", bc.name, err))
+			case distinct != 1 && isKnown:
+				details = append(details, fmt.Sprintf("%s: deterministic mode: %s (known issue: %s)", bc.name, detStatus, known))
+			case distinct != 1:
+				t.Errorf("%s: deterministic mode: %s", bc.name, detStatus)
+				details = append(details, fmt.Sprintf("%s: deterministic mode: %s", bc.name, detStatus))
+			case isKnown:
+				t.Errorf("%s: deterministic mode: identical, but listed as a known issue (fixed?): %s", bc.name, known)
+			}
+		}
+		distinct, size, err := benchDeterministicCheck(bc.encodefn, w.v, n)
+		if err != nil {
+			details = append(details, fmt.Sprintf("%s: default mode: %v", bc.name, err))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s/%d\n", bc.name, detStatus, benchDeterministicStatus(distinct, n, err), detSize, size)
+	}
+	tw.Flush()
+	for _, s := range details {
+		benchOnePassLogf("\t%s", benchInteropTrim(s))
+	}
+}
+
+func Benchmark__Deterministic(b *testing.B) {
+	checkers := benchCheckersFor(func(x *benchChecker) bool { return x.detenc != nil && x.runnable() })
+	for _, mode := range [...]string{"default", "deterministic"} {
+		b.Run(mode, func(b *testing.B) {
+			for _, bc := range checkers {
+				fn := bc.encodefn
+				if mode == "deterministic" {
+					fn = bc.detenc
+				}
+				b.Run(bc.title, func(b *testing.B) {
+					bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+						fnBenchmarkEncode(b, bc.title, w.v, fn, w.baseLen)
+					})
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines benchmarks which encode and decode through fragmented or slow IO
+// (see testIOWrap), to measure how each library's internal buffering copes with network-like IO
+// e.g. short reads, many small writes, or latency on each call.
+//
+// They run through the wrapper configured by -tiw1, -tiwc and -tiwl if any,
+// else through each of: one byte per call, random chunks of up to 512 bytes,
+// and random chunks of up to 512 bytes with a latency of 20µs per call.
+//
+// They cover the checkers which support streams (see benchChecker.streamenc),
+// as those accept any io.Reader and io.Writer. codec buffers as configured by -ti.
+//
+// Sample way to run:
+//    go test -tags x -run XXX -bench __Fragmented
+//    go test -tags x -run XXX -bench __Fragmented -ti 4096 -tiwc 64 -tiwl 100us
+//
+// Benchmarks are named after the wrapper and checker e.g. Benchmark__FragmentedDecode/byte/Json.
+// Each op encodes (or decodes) one value, using a new encoder (or decoder).
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// benchIOWraps returns the wrappers which the fragmented IO benchmarks run through.
+func benchIOWraps() []testIOWrap {
+	if !testv.IoWrap.passThrough() {
+		return []testIOWrap{testv.IoWrap}
+	}
+	return []testIOWrap{
+		{OneByte: true},
+		{MaxChunk: 512},
+		{MaxChunk: 512, Latency: 20 * time.Microsecond},
+	}
+}
+
+func Benchmark__FragmentedEncode(b *testing.B) {
+	for _, wrap := range benchIOWraps() {
+		b.Run(wrap.String(), func(b *testing.B) {
+			for _, bc := range benchCheckersFor(benchStreamable) {
+				b.Run(bc.title, func(b *testing.B) {
+					bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+						fnBenchmarkFragmentedEncode(b, bc, w, wrap)
+					})
+				})
+			}
+		})
+	}
+}
+
+func Benchmark__FragmentedDecode(b *testing.B) {
+	for _, wrap := range benchIOWraps() {
+		b.Run(wrap.String(), func(b *testing.B) {
+			for _, bc := range benchCheckersFor(benchStreamable) {
+				b.Run(bc.title, func(b *testing.B) {
+					bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+						fnBenchmarkFragmentedDecode(b, bc, w, wrap)
+					})
+				})
+			}
+		})
+	}
+}
+
+func fnBenchmarkFragmentedEncode(b *testing.B, bc *benchChecker, w *benchWorkload, wrap testIOWrap) {
+	defer benchRecoverPanic(b)
+	var buf bytes.Buffer
+	wrapfn := wrap.writerFn()
+	b.ReportAllocs()
+	fnBenchmarkRun(b, func() {
+		buf.Reset()
+		if err := bc.streamenc(wrapfn(&buf))(w.v); err != nil {
+			b.Logf("Error encoding %T: %s (%s): %v", w.v, bc.name, wrap, err)
+			b.FailNow()
+		}
+	})
+	fnBenchmarkReportSize(b, buf.Len(), w.baseLen)
+}
+
+func fnBenchmarkFragmentedDecode(b *testing.B, bc *benchChecker, w *benchWorkload, wrap testIOWrap) {
+	defer benchRecoverPanic(b)
+	var buf bytes.Buffer
+	if err := benchStreamEncode(&buf, bc.streamenc, w.v, 1); err != nil {
+		b.Logf("Error encoding %T: %s: %v", w.v, bc.name, err)
+		b.FailNow()
+	}
+	bs := buf.Bytes()
+	r := bytes.NewReader(nil)
+	wrapfn := wrap.readerFn()
+	b.ReportAllocs()
+	fnBenchmarkRun(b, func() {
+		r.Reset(bs)
+		if err := bc.streamdec(wrapfn(r))(w.newfn()); err != nil {
+			b.Logf("Error decoding into new %T: %s (%s): %v", w.v, bc.name, wrap, err)
+			b.FailNow()
+		}
+	})
+	fnBenchmarkReportSize(b, len(bs), w.baseLen)
+}
+
+// benchFragmentedIOIssue is a known issue of a checker with fragmented IO.
+type benchFragmentedIOIssue struct {
+	desc string
+	// match reports whether the failure to round-trip a workload's value is this issue.
+	match func(want, got interface{}, err error) bool
+	// occurs reports whether the issue must occur for the workload with the wrapper,
+	// so a fix is noticed (and the issue removed).
+	occurs func(w *benchWorkload, wrap testIOWrap) bool
+}
+
+// benchFragmentedIOIssues are the known issues of checkers with fragmented IO, keyed by name.
+// The benchmarks still run them, as the cost of buffering is what they measure.
+var benchFragmentedIOIssues = map[string]benchFragmentedIOIssue{
+	"goccyjson": {
+		desc: "corrupts multi-byte UTF-8 characters split across short reads",
+		match: func(want, got interface{}, err error) bool {
+			var d testMismatchReport
+			return errors.As(err, &d) && benchJsonNonASCIIRe.ReplaceAllString(benchStdJson(want), "?") ==
+				benchJsonNonASCIIRe.ReplaceAllString(benchStdJson(got), "?")
+		},
+		occurs: func(w *benchWorkload, wrap testIOWrap) bool {
+			// every multi-byte character is split, when reading one byte at a time
+			return wrap.OneByte && benchJsonNonASCIIRe.MatchString(benchStdJson(w.v))
+		},
+	},
+}
+
+// benchJsonNonASCIIRe matches a run of non-ASCII characters (including U+FFFD, for invalid UTF-8).
+var benchJsonNonASCIIRe = regexp.MustCompile(`[^\x00-\x7f]+`)
+
+// benchStdJson returns v encoded by encoding/json, or the error.
+func benchStdJson(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(bs)
+}
+
+// TestBenchFragmentedIO checks that streams round-trip through fragmented and slow IO,
+// for each checker which supports streams, unbuffered and buffered (for codec).
+// A checker with a known issue must fail only with that issue (and with it, where it must occur).
+func TestBenchFragmentedIO(t *testing.T) {
+	defer func() {
+		tbvars.setBufsize((int)(testv.bufsize))
+		testReinit()
+	}()
+	// no latency, as each call would sleep for at least the timer resolution
+	wraps := [...]testIOWrap{{OneByte: true}, {MaxChunk: 7}}
+	for _, bufsize := range [...]int{0, 64} {
+		tbvars.setBufsize(bufsize)
+		testReinit()
+		for _, wrap := range wraps {
+			for _, bc := range benchCheckersFor(benchStreamable) {
+				issue, known := benchFragmentedIOIssues[bc.name]
+				for _, w := range benchWorkloadsSelected {
+					if bc.skipReason(w) != "" {
+						continue
+					}
+					got, err := benchStreamRoundTrip(bc, w, 1, &wrap)
+					switch {
+					case !known:
+						if err != nil {
+							t.Error(err)
+						}
+					case err != nil && issue.match(w.v, got, err):
+						t.Logf("%s: %s: %s: known issue: %s", bc.name, w.name, wrap, issue.desc)
+					case err != nil:
+						t.Errorf("%v\n(not the known issue: %s)", err, issue.desc)
+					case issue.occurs(w, wrap):
+						t.Errorf("%s: %s: %s: known issue did not occur (fixed?): %s", bc.name, w.name, wrap, issue.desc)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file checks interoperability between libraries sharing a wire format.
+//
+// fnBenchmarkDecode decodes bytes produced by the codec encoder for a format family
+// (json, cbor, msgpack) using each library in that family. Here, we check that this
+// actually works, by encoding benchTs with every producer and decoding it with every consumer.
+//
+// Sample way to run:
+//    go test -tags x -run BenchInterop -v
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+type benchInteropStatus uint8
+
+const (
+	benchInteropOk benchInteropStatus = iota
+	benchInteropMismatch
+	benchInteropError
+)
+
+func (x benchInteropStatus) String() string {
+	switch x {
+	case benchInteropOk:
+		return "ok"
+	case benchInteropMismatch:
+		return "mismatch"
+	case benchInteropError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// benchInteropFormats returns the format families which have at least 2 runnable checkers,
+// in registration order, along with those checkers.
+func benchInteropFormats(w *benchWorkload) (formats []benchFormat, m map[benchFormat][]*benchChecker) {
+	m = make(map[benchFormat][]*benchChecker)
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.skipReason(w) == "" }) {
+		if _, ok := m[bc.format]; !ok {
+			formats = append(formats, bc.format)
+		}
+		m[bc.format] = append(m[bc.format], bc)
+	}
+	var j int
+	for _, f := range formats {
+		if len(m[f]) > 1 {
+			formats[j] = f
+			j++
+		}
+	}
+	formats = formats[:j]
+	return
+}
+
+// benchInteropCheck encodes the workload value with producer p, and decodes it with consumer c.
+func benchInteropCheck(p, c *benchChecker, w *benchWorkload) (status benchInteropStatus, detail string) {
+	defer func() {
+		if r := recover(); r != nil {
+			status, detail = benchInteropError, fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	buf, err := p.encodefn(w.v, nil)
+	if err != nil {
+		return benchInteropError, "encode: " + err.Error()
+	}
+	ts2 := w.new()
+	if err = c.decodefn(buf, ts2); err != nil {
+		return benchInteropError, "decode: " + err.Error()
+	}
+	// if either side cannot distinguish nil from empty, then compare them as equal
+	nilEmptyEqual := (p.adapted(w)|c.adapted(w))&benchCapNilVsEmpty != 0
+	if v := testDiff(w.v, ts2, nilEmptyEqual); len(v) != 0 {
+		return benchInteropMismatch, v.summary() + ": " + v[0].String()
+	}
+	return
+}
+
+func TestBenchInterop(t *testing.T) {
+	w := benchWorkloadFind(benchWorkloadDefault)
+	formats, m := benchInteropFormats(w)
+	for _, f := range formats {
+		checkers := m[f]
+		t.Run(string(f), func(t *testing.T) {
+			var details []string
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "%s: producer \\ consumer\t", f)
+			for _, c := range checkers {
+				fmt.Fprintf(tw, "%s\t", c.name)
+			}
+			fmt.Fprintln(tw)
+			for _, p := range checkers {
+				fmt.Fprintf(tw, "%s\t", p.name)
+				for _, c := range checkers {
+					status, detail := benchInteropCheck(p, c, w)
+					fmt.Fprintf(tw, "%s\t", status)
+					if status == benchInteropOk {
+						continue
+					}
+					details = append(details, fmt.Sprintf("%s -> %s: %s: %s", p.name, c.name, status, benchInteropTrim(detail)))
+					// fnBenchmarkDecode depends on every library decoding what codec encodes
+					if p.group == benchGroupCodec && status == benchInteropError {
+						t.Errorf("%s -> %s: %s", p.name, c.name, detail)
+					}
+				}
+				fmt.Fprintln(tw)
+			}
+			tw.Flush()
+			for _, s := range details {
+				benchOnePassLogf("\t%s", s)
+			}
+		})
+	}
+}
+
+// benchInteropTrim trims a (possibly multi-line) detail to its first line.
+func benchInteropTrim(s string) string {
+	const max = 160
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " ..."
+	}
+	if len(s) > max {
+		s = s[:max] + " ..."
+	}
+	return s
+}
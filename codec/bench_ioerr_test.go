@@ -0,0 +1,140 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file checks that IO errors are propagated by every library, rather than causing
+// a panic, a hang or a silently truncated value.
+//
+// Each checker which supports streams (see benchChecker.streamenc) encodes the default workload
+// to a writer which fails after N bytes, and decodes it from a reader which returns
+// io.ErrUnexpectedEOF or a custom error after N bytes, for N at the start, middle and
+// last byte of the value. For codec, this uses Encode and Decode (not MustEncode and MustDecode).
+//
+// Checkers without streams only encode to (and decode from) []byte in this harness,
+// so they are listed as skipped.
+//
+// Sample way to run:
+//    go test -tags x -run BenchIOErrors -v
+//    go test -tags x -run BenchIOErrors -v -ti 1024 # with codec buffering
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+const benchIOErrTimeout = 10 * time.Second
+
+// benchIOErrCase is an injected IO failure.
+type benchIOErrCase struct {
+	write bool  // fail the writer (else the reader)
+	err   error // error returned
+	at    int   // fail after this many bytes: 0 (start), 1 (middle) or 2 (last byte) of the value
+}
+
+func (x benchIOErrCase) String() string {
+	s := "r-err"
+	if x.write {
+		s = "w-err"
+	} else if x.err == io.ErrUnexpectedEOF {
+		s = "r-eof"
+	}
+	return s + "@" + [...]string{"start", "mid", "last"}[x.at]
+}
+
+// n returns the number of bytes to allow, for a value of length sz.
+func (x benchIOErrCase) n(sz int) int {
+	return [...]int{0, sz / 2, sz - 1}[x.at]
+}
+
+func benchIOErrCases() (v []benchIOErrCase) {
+	for _, c := range [...]benchIOErrCase{
+		{write: true, err: errTestIOInjected},
+		{err: io.ErrUnexpectedEOF},
+		{err: errTestIOInjected},
+	} {
+		for at := 0; at < 3; at++ {
+			c.at = at
+			v = append(v, c)
+		}
+	}
+	return
+}
+
+// benchIOErrRun runs fn, returning the outcome: error if the injected error (or, for io.ErrUnexpectedEOF,
+// an error) came back, other if an error came back which does not wrap it, accepted (i.e. a silently
+// truncated value), panic or hang.
+func benchIOErrRun(c benchIOErrCase, fn func() error) (o benchOutcome, detail string) {
+	o, err, detail := benchGuard(benchIOErrTimeout, fn)
+	switch {
+	case o == benchOutcomeAccepted:
+		return o, "no error"
+	case o != benchOutcomeError:
+		return o, detail
+	case errors.Is(err, c.err) || c.err == io.ErrUnexpectedEOF:
+		return o, ""
+	}
+	return benchOutcomeOther, detail
+}
+
+// benchIOErrCheck injects the failure while encoding (or decoding) the workload value with bc.
+func benchIOErrCheck(bc *benchChecker, w *benchWorkload, c benchIOErrCase) (o benchOutcome, detail string) {
+	var buf bytes.Buffer
+	if err := benchStreamEncode(&buf, bc.streamenc, w.v, 1); err != nil {
+		return benchOutcomeOther, "encode (without failure): " + err.Error()
+	}
+	bs := buf.Bytes()
+	sz := len(bs)
+	if bc.format == benchFormatJson {
+		sz = len(bytes.TrimRight(bs, "\n")) // so the last byte is part of the value, not the framing
+	}
+	if c.write {
+		return benchIOErrRun(c, func() error {
+			return bc.streamenc(&ioFailingWriter{w: io.Discard, n: c.n(sz), err: c.err})(w.v)
+		})
+	}
+	return benchIOErrRun(c, func() error {
+		return bc.streamdec(&ioFailingReader{r: bytes.NewReader(bs), n: c.n(sz), err: c.err})(w.new())
+	})
+}
+
+func TestBenchIOErrors(t *testing.T) {
+	w := benchWorkloadFind(benchWorkloadDefault)
+	cases := benchIOErrCases()
+	tw := benchNewOutcomeTable()
+	fmt.Fprintf(tw, "checker\t")
+	for _, c := range cases {
+		fmt.Fprintf(tw, "%s\t", c)
+	}
+	fmt.Fprintln(tw)
+	for _, bc := range benchCheckersFor(nil) {
+		if reason := bc.skipReason(w); reason != "" {
+			continue
+		}
+		if !benchStreamable(bc) {
+			tw.note("%s: skipped: no io.Reader/io.Writer support in the harness", bc.name)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t", bc.name)
+		for _, c := range cases {
+			o, detail := benchIOErrCheck(bc, w, c)
+			fmt.Fprintf(tw, "%s\t", o)
+			if o == benchOutcomeError {
+				continue
+			}
+			tw.note("%s: %s: %s: %s", bc.name, c, o, benchInteropTrim(detail))
+			// codec must return the error, and not lose it
+			if bc.group == benchGroupCodec {
+				t.Errorf("%s: %s: %s: %s", bc.name, c, o, detail)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.flush()
+}
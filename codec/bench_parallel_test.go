@@ -0,0 +1,127 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines parallel variants of the encode and decode benchmarks,
+// which measure throughput when encoding and decoding concurrently (using b.RunParallel).
+//
+// Each goroutine uses its own encode/decode functions (see benchChecker.newenc)
+// e.g. for codec, its own Encoder and Decoder sharing the Handle,
+// so what is measured includes contention on the Handle (and the library's shared state).
+//
+// Run them across GOMAXPROCS values using -cpu e.g.
+//    go test -tags x -run XXX -bench '__Parallel' -cpu 1,2,4,8 > parallel.txt
+//    go run ../cmd/benchreport -o report parallel.txt
+//
+// ns/op is the wall time per operation across all goroutines,
+// so it falls as throughput scales with GOMAXPROCS.
+
+import (
+	"runtime"
+	"testing"
+)
+
+func Benchmark__ParallelEncode(b *testing.B) {
+	for _, bc := range benchCheckersFor(nil) {
+		b.Run(bc.title, bc.benchParallelEncode)
+	}
+}
+
+func Benchmark__ParallelDecode(b *testing.B) {
+	for _, bc := range benchCheckersFor(nil) {
+		b.Run(bc.title, bc.benchParallelDecode)
+	}
+}
+
+// goroutineEncFn returns the encode function for use by a single goroutine.
+func (x *benchChecker) goroutineEncFn() benchEncFn {
+	if x.newenc != nil {
+		return x.newenc()
+	}
+	return x.encodefn
+}
+
+// goroutineDecFn returns the decode function for use by a single goroutine.
+func (x *benchChecker) goroutineDecFn() benchDecFn {
+	if x.newdec != nil {
+		return x.newdec()
+	}
+	return x.decodefn
+}
+
+func (x *benchChecker) benchParallelEncode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		fnBenchmarkParallelEncode(b, x, w)
+	})
+}
+
+func (x *benchChecker) benchParallelDecode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		fnBenchmarkParallelDecode(b, x, w)
+	})
+}
+
+func fnBenchmarkParallelEncode(b *testing.B, bc *benchChecker, w *benchWorkload) {
+	defer benchRecoverPanic(b)
+	bs, err := bc.encodefn(w.v, nil)
+	if err != nil {
+		b.Logf("Error encoding %T: %s: %v", w.v, bc.name, err)
+		b.FailNow()
+	}
+	fnBenchmarkRunParallel(b, func() func() error {
+		encfn := bc.goroutineEncFn()
+		buf := make([]byte, 0, len(bs))
+		return func() (err error) {
+			_, err = encfn(w.v, buf)
+			return
+		}
+	})
+	fnBenchmarkReportSize(b, len(bs), w.baseLen)
+}
+
+func fnBenchmarkParallelDecode(b *testing.B, bc *benchChecker, w *benchWorkload) {
+	defer benchRecoverPanic(b)
+	// decode the same bytes as fnBenchmarkDecode
+	buf, err := benchDecodeEncodeFn(bc)(w.v, nil)
+	if err != nil {
+		b.Logf("Error encoding %T: %s: %v", w.v, bc.name, err)
+		b.FailNow()
+	}
+	fnBenchmarkRunParallel(b, func() func() error {
+		decfn := bc.goroutineDecFn()
+		newfn := w.newReusable()
+		return func() error {
+			return decfn(buf, newfn())
+		}
+	})
+	fnBenchmarkReportSize(b, len(buf), w.baseLen)
+}
+
+// fnBenchmarkRunParallel runs the benchmark using b.RunParallel.
+//
+// newfn is called once per goroutine (before timing starts) to get the function to run on each iteration.
+// The first error (or panic) stops the goroutine, and fails the benchmark.
+func fnBenchmarkRunParallel(b *testing.B, newfn func() func() error) {
+	b.ReportAllocs()
+	runtime.GC()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		if benchRecover {
+			defer func() {
+				if r := recover(); r != nil {
+					b.Errorf("(recovered) panic: %v", r)
+				}
+			}()
+		}
+		fn := newfn()
+		for pb.Next() {
+			if err := fn(); err != nil {
+				b.Errorf("Error: %v", err)
+				return
+			}
+		}
+	})
+}
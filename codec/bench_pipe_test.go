@@ -0,0 +1,203 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines Benchmark__Pipe, which streams large payloads (-bpm MB) through an io.Pipe,
+// from a producer goroutine encoding records to a consumer goroutine decoding them,
+// so we can see which libraries process large exports without buffering everything.
+//
+// It runs across reader/writer buffer sizes (-bpb):
+//   - codec uses them as the ReaderBufferSize and WriterBufferSize of its Handles
+//   - other libraries have no such option, so the pipe is wrapped in a bufio.Reader and bufio.Writer
+//     of that size (or not wrapped, if 0)
+//
+// It covers the checkers which support streams (see benchChecker.streamenc).
+//
+// Sample way to run:
+//    go test -tags x -run XXX -bench __Pipe -bpm 512 -bpb 0,65536
+//
+// Benchmarks are named after the buffer size and checker e.g. Benchmark__Pipe/buf-4096/Json.
+// Each op streams the whole payload. In addition to the metrics reported by every benchmark
+// (see fnBenchmarkReportSize), peak-heap-bytes is the peak size of heap objects, including garbage
+// not yet collected (sampled every millisecond), above what was in use before the benchmark started.
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Benchmark__Pipe(b *testing.B) {
+	sizes, err := benchSweepInts(testv.BenchmarkPipeBufferSizes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		tbvars.setBufsize((int)(testv.bufsize))
+		testReinit()
+	}()
+	for _, bufsize := range sizes {
+		tbvars.setBufsize(bufsize)
+		testReinit()
+		b.Run("buf-"+strconv.Itoa(bufsize), func(b *testing.B) {
+			for _, bc := range benchCheckersFor(benchStreamable) {
+				b.Run(bc.title, func(b *testing.B) {
+					bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+						fnBenchmarkPipe(b, bc, w, bufsize)
+					})
+				})
+			}
+		})
+	}
+}
+
+func fnBenchmarkPipe(b *testing.B, bc *benchChecker, w *benchWorkload, bufsize int) {
+	defer benchRecoverPanic(b)
+	var buf bytes.Buffer
+	if err := benchStreamEncode(&buf, bc.streamenc, w.v, 1); err != nil {
+		b.Logf("Error encoding %T: %s: %v", w.v, bc.name, err)
+		b.FailNow()
+	}
+	records := max(1, testv.BenchmarkPipeMB<<20/buf.Len())
+	var size int64
+	peak := benchHeapPeak()
+	fnBenchmarkRun(b, func() {
+		var err error
+		if size, err = benchPipe(bc, w, records, bufsize); err != nil {
+			b.Logf("Error piping %d %T: %s: %v", records, w.v, bc.name, err)
+			b.FailNow()
+		}
+	})
+	b.ReportMetric(float64(peak()), "peak-heap-bytes")
+	fnBenchmarkReportSize(b, int(size), 0)
+}
+
+// benchPipe streams records copies of the workload's value through an io.Pipe,
+// from a producer goroutine to the consumer (the calling goroutine),
+// returning the number of bytes streamed.
+//
+// Non-codec libraries are given buffered readers and writers of bufsize (see Benchmark__Pipe).
+func benchPipe(bc *benchChecker, w *benchWorkload, records, bufsize int) (n int64, err error) {
+	pr, pw := io.Pipe()
+	cw := &benchCountWriter{w: pw}
+	buffered := bc.group != benchGroupCodec && bufsize > 0
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		if buffered {
+			bw := bufio.NewWriterSize(cw, bufsize)
+			if err = benchPipeProduce(bw, bc, w.v, records); err == nil {
+				err = bw.Flush()
+			}
+		} else {
+			err = benchPipeProduce(cw, bc, w.v, records)
+		}
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+	var r io.Reader = pr
+	if buffered {
+		r = bufio.NewReaderSize(pr, bufsize)
+	}
+	decfn := bc.streamdec(r)
+	for i := 0; i < records && err == nil; i++ {
+		err = decfn(w.newfn())
+	}
+	if err == nil {
+		// drain any trailing bytes e.g. a newline not consumed by the decoder
+		_, err = io.Copy(io.Discard, r)
+	}
+	pr.CloseWithError(err) // unblock the producer, if the consumer stopped early
+	if perr := <-errc; err == nil {
+		err = perr
+	}
+	return cw.n, err
+}
+
+func benchPipeProduce(wr io.Writer, bc *benchChecker, v interface{}, records int) error {
+	encfn := bc.streamenc(wr)
+	for i := 0; i < records; i++ {
+		if err := encfn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchCountWriter counts the bytes written to w.
+type benchCountWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (x *benchCountWriter) Write(p []byte) (n int, err error) {
+	n, err = x.w.Write(p)
+	x.n += int64(n)
+	return
+}
+
+// benchHeapPeak starts sampling the heap every millisecond, after a GC,
+// and returns a function which stops sampling and returns the peak heap
+// above what was in use when sampling started.
+func benchHeapPeak() (stop func() uint64) {
+	const name = "/memory/classes/heap/objects:bytes"
+	read := func() uint64 {
+		s := [1]metrics.Sample{{Name: name}}
+		metrics.Read(s[:])
+		return s[0].Value.Uint64()
+	}
+	runtime.GC()
+	base := read()
+	peak := base
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		t := time.NewTicker(time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				peak = max(peak, read())
+			}
+		}
+	}()
+	return func() uint64 {
+		close(done)
+		<-finished
+		return peak - base
+	}
+}
+
+// TestBenchPipeCheck checks that a few records of each workload stream through the pipe,
+// for each checker, unbuffered and buffered.
+func TestBenchPipeCheck(t *testing.T) {
+	defer func() {
+		tbvars.setBufsize((int)(testv.bufsize))
+		testReinit()
+	}()
+	for _, bufsize := range [...]int{0, 64} {
+		tbvars.setBufsize(bufsize)
+		testReinit()
+		for _, bc := range benchCheckersFor(benchStreamable) {
+			for _, w := range benchWorkloadsSelected {
+				if bc.skipReason(w) != "" {
+					continue
+				}
+				if n, err := benchPipe(bc, w, 3, bufsize); err != nil || n == 0 {
+					t.Errorf("%s: %s: buf-%d: error piping (%d bytes): %v", bc.name, w.name, bufsize, n, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file writes machine-readable results of the one-pass check (TestBenchOnePassCheck).
+//
+// Sample way to run:
+//    go test -tags x -run BenchOnePassCheck -bo=onepass.json
+//    go test -tags x -run BenchOnePassCheck -bo=onepass.csv -bof=csv
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchOnePassResult is the outcome of running one benchChecker
+// through an encode/decode round-trip.
+type benchOnePassResult struct {
+	Name       string             `json:"name"`
+	Workload   string             `json:"workload"`
+	Depth      int                `json:"depth"`
+	EncodedLen int                `json:"encodedLen"`
+	EncodeNs   int64              `json:"encodeNs"`
+	DecodeNs   int64              `json:"decodeNs"`
+	Equal      bool               `json:"equal"`
+	Adapted    string             `json:"adapted,omitempty"`
+	Skipped    string             `json:"skipped,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Panic      string             `json:"panic,omitempty"`
+	Mismatches testMismatchReport `json:"mismatches,omitempty"`
+}
+
+var benchOnePassCsvHeader = []string{
+	"name", "workload", "depth", "encodedLen", "encodeNs", "decodeNs",
+	"equal", "adapted", "skipped", "error", "panic", "mismatches",
+}
+
+func (x *benchOnePassResult) csvRecord() []string {
+	return []string{
+		x.Name, x.Workload, strconv.Itoa(x.Depth), strconv.Itoa(x.EncodedLen),
+		strconv.FormatInt(x.EncodeNs, 10), strconv.FormatInt(x.DecodeNs, 10),
+		strconv.FormatBool(x.Equal), x.Adapted, x.Skipped, x.Error, x.Panic, x.Mismatches.csvField(),
+	}
+}
+
+// csvField flattens the mismatches into a single field e.g. "path1:category1;path2:category2".
+func (x testMismatchReport) csvField() string {
+	var sb strings.Builder
+	for i, m := range x {
+		if i > 0 {
+			sb.WriteByte(';')
+		}
+		sb.WriteString(m.Path)
+		sb.WriteByte(':')
+		sb.WriteString(m.Category.String())
+	}
+	return sb.String()
+}
+
+func benchOnePassWriteResults(file, format string, results []benchOnePassResult) (err error) {
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("unsupported one-pass results format: %q (supported: json, csv)", format)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+	}()
+	if format == "json" {
+		e := json.NewEncoder(f)
+		e.SetIndent("", "  ")
+		return e.Encode(results)
+	}
+	w := csv.NewWriter(f)
+	w.Write(benchOnePassCsvHeader)
+	for i := range results {
+		w.Write(results[i].csvRecord())
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func TestBenchOnePassWriteResults(t *testing.T) {
+	results := []benchOnePassResult{
+		{Name: "msgpack", Workload: "teststruc", Depth: 1, EncodedLen: 40107, EncodeNs: 1500, DecodeNs: 2500, Equal: true},
+		{Name: "gob", Workload: "teststruc", Depth: 1, EncodedLen: 38142, EncodeNs: 3000, DecodeNs: 4000,
+			Adapted: "nil-vs-empty", Mismatches: testMismatchReport{
+				{Path: "TestStruc.AI64slice0", Category: testMismatchNilVsEmpty, Want: "empty", Got: "nil"},
+				{Path: "TestStruc.F32", Category: testMismatchPrecisionLoss, Want: "1.5", Got: "1.4999999"},
+			}},
+		{Name: "std-xml", Workload: "teststruc", Depth: 1,
+			Skipped: "std-xml cannot handle workload teststruc: missing capabilities: fixed-arrays,maps"},
+		{Name: "xdr", Workload: "teststruc", Depth: 1, Error: "line 1\nline \"2\", with a comma", Panic: "oops"},
+	}
+	// optional are the fields omitted from the json when empty
+	optional := func(x *benchOnePassResult) map[string]string {
+		return map[string]string{"adapted": x.Adapted, "skipped": x.Skipped, "error": x.Error, "panic": x.Panic}
+	}
+	dir := t.TempDir()
+
+	jsonFile := filepath.Join(dir, "onepass.json")
+	if err := benchOnePassWriteResults(jsonFile, "json", results); err != nil {
+		t.Fatalf("error writing json: %v", err)
+	}
+	bs, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objs []map[string]interface{}
+	if err = json.Unmarshal(bs, &objs); err != nil {
+		t.Fatalf("error reading json: %v", err)
+	}
+	if len(objs) != len(results) {
+		t.Fatalf("json: got %d results, want %d", len(objs), len(results))
+	}
+	for i := range results {
+		x := &results[i]
+		want := map[string]interface{}{
+			"name": x.Name, "workload": x.Workload, "depth": float64(x.Depth), "encodedLen": float64(x.EncodedLen),
+			"encodeNs": float64(x.EncodeNs), "decodeNs": float64(x.DecodeNs), "equal": x.Equal,
+		}
+		for k, v := range optional(x) {
+			if v != "" {
+				want[k] = v
+			}
+		}
+		if len(x.Mismatches) != 0 {
+			var ms []interface{}
+			for _, m := range x.Mismatches {
+				ms = append(ms, map[string]interface{}{"path": m.Path, "category": m.Category.String(), "want": m.Want, "got": m.Got})
+			}
+			want["mismatches"] = ms
+		}
+		if !reflect.DeepEqual(objs[i], want) {
+			t.Errorf("json: result %d: got %v, want %v", i, objs[i], want)
+		}
+	}
+
+	csvFile := filepath.Join(dir, "onepass.csv")
+	if err = benchOnePassWriteResults(csvFile, "csv", results); err != nil {
+		t.Fatalf("error writing csv: %v", err)
+	}
+	f, err := os.Open(csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading csv: %v", err)
+	}
+	header := []string{"name", "workload", "depth", "encodedLen", "encodeNs", "decodeNs",
+		"equal", "adapted", "skipped", "error", "panic", "mismatches"}
+	if len(records) != len(results)+1 || !reflect.DeepEqual(records[0], header) {
+		t.Fatalf("csv: got %d records with header %q, want %d with header %q", len(records), records[0], len(results)+1, header)
+	}
+	if s, want := records[2][len(header)-1], "TestStruc.AI64slice0:nil-vs-empty;TestStruc.F32:precision-loss"; s != want {
+		t.Errorf("csv: mismatches: got %q, want %q", s, want)
+	}
+	for i := range results {
+		x := &results[i]
+		want := map[string]string{
+			"name": x.Name, "workload": x.Workload, "depth": strconv.Itoa(x.Depth), "encodedLen": strconv.Itoa(x.EncodedLen),
+			"encodeNs": strconv.FormatInt(x.EncodeNs, 10), "decodeNs": strconv.FormatInt(x.DecodeNs, 10),
+			"equal": strconv.FormatBool(x.Equal), "mismatches": x.Mismatches.csvField(),
+		}
+		for k, v := range optional(x) {
+			want[k] = v
+		}
+		got := make(map[string]string)
+		for j, k := range header {
+			got[k] = records[i+1][j]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("csv: result %d: got %q, want %q", i, got, want)
+		}
+	}
+
+	if err = benchOnePassWriteResults(filepath.Join(dir, "onepass.xml"), "xml", results); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
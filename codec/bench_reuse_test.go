@@ -0,0 +1,222 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file compares strategies for getting an encoder (or decoder) for each operation,
+// as a request handler would:
+//   - new:   construct one per call
+//   - reset: reset a long-lived one (owned by the goroutine)
+//   - pool:  get one from a sync.Pool, and put it back after
+//
+// It covers the checkers which expose reusable encoders or decoders (see benchChecker.newenc):
+// codec (all handles), json-iter, goccyjson, fxcbor and v-msgpack.
+// goccyjson and fxcbor decoders cannot be reset to new input, so they only run the encode benchmarks.
+//
+// Sample way to run:
+//    go test -tags x -run XXX -bench '__Reuse'
+//    go test -tags x -run XXX -bench '__Reuse' -tp -cpu 1,4 # in parallel: reset is per goroutine, pool is shared
+//
+// Benchmarks are named after the checker and strategy e.g. Benchmark__ReuseEncode/Json/pool.
+
+import (
+	"sync"
+	"testing"
+)
+
+// benchStrategy is a way of getting an encoder (or decoder) for each operation.
+type benchStrategy uint8
+
+const (
+	benchStrategyNew   benchStrategy = iota // construct one per call
+	benchStrategyReset                      // reset a long-lived one, owned by the goroutine
+	benchStrategyPool                       // get one from a sync.Pool, shared by all goroutines
+)
+
+var benchStrategies = [...]benchStrategy{benchStrategyNew, benchStrategyReset, benchStrategyPool}
+
+func (x benchStrategy) String() string {
+	switch x {
+	case benchStrategyNew:
+		return "new"
+	case benchStrategyReset:
+		return "reset"
+	case benchStrategyPool:
+		return "pool"
+	}
+	return "unknown"
+}
+
+// encoders returns a func which returns the encode function for a goroutine,
+// which gets its encoder using this strategy (from newenc).
+func (x benchStrategy) encoders(newenc func() benchEncFn) func() benchEncFn {
+	switch x {
+	case benchStrategyNew:
+		return func() benchEncFn {
+			return func(ts interface{}, bsIn []byte) ([]byte, error) {
+				return newenc()(ts, bsIn)
+			}
+		}
+	case benchStrategyPool:
+		p := &sync.Pool{New: func() interface{} { return newenc() }}
+		return func() benchEncFn {
+			return func(ts interface{}, bsIn []byte) (bs []byte, err error) {
+				fn := p.Get().(benchEncFn)
+				bs, err = fn(ts, bsIn)
+				p.Put(fn)
+				return
+			}
+		}
+	}
+	return newenc
+}
+
+// decoders returns a func which returns the decode function for a goroutine,
+// which gets its decoder using this strategy (from newdec).
+func (x benchStrategy) decoders(newdec func() benchDecFn) func() benchDecFn {
+	switch x {
+	case benchStrategyNew:
+		return func() benchDecFn {
+			return func(buf []byte, ts interface{}) error {
+				return newdec()(buf, ts)
+			}
+		}
+	case benchStrategyPool:
+		p := &sync.Pool{New: func() interface{} { return newdec() }}
+		return func() benchDecFn {
+			return func(buf []byte, ts interface{}) (err error) {
+				fn := p.Get().(benchDecFn)
+				err = fn(buf, ts)
+				p.Put(fn)
+				return
+			}
+		}
+	}
+	return newdec
+}
+
+func Benchmark__ReuseEncode(b *testing.B) {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.newenc != nil }) {
+		b.Run(bc.title, func(b *testing.B) {
+			for _, s := range benchStrategies {
+				b.Run(s.String(), func(b *testing.B) {
+					bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+						fnBenchmarkReuseEncode(b, bc, w, s)
+					})
+				})
+			}
+		})
+	}
+}
+
+func Benchmark__ReuseDecode(b *testing.B) {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.newdec != nil }) {
+		b.Run(bc.title, func(b *testing.B) {
+			for _, s := range benchStrategies {
+				b.Run(s.String(), func(b *testing.B) {
+					bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+						fnBenchmarkReuseDecode(b, bc, w, s)
+					})
+				})
+			}
+		})
+	}
+}
+
+func fnBenchmarkReuseEncode(b *testing.B, bc *benchChecker, w *benchWorkload, s benchStrategy) {
+	defer benchRecoverPanic(b)
+	bs, err := bc.encodefn(w.v, nil)
+	if err != nil {
+		b.Logf("Error encoding %T: %s: %v", w.v, bc.name, err)
+		b.FailNow()
+	}
+	encoders := s.encoders(bc.newenc)
+	b.ReportAllocs()
+	if testv.UseParallel {
+		fnBenchmarkRunParallel(b, func() func() error {
+			encfn := encoders()
+			buf := make([]byte, 0, len(bs))
+			return func() (err error) {
+				_, err = encfn(w.v, buf)
+				return
+			}
+		})
+	} else {
+		encfn := encoders()
+		buf := make([]byte, 0, len(bs))
+		fnBenchmarkRun(b, func() {
+			if _, err = encfn(w.v, buf); err != nil {
+				b.Logf("Error encoding %T: %s (%s): %v", w.v, bc.name, s, err)
+				b.FailNow()
+			}
+		})
+	}
+	fnBenchmarkReportSize(b, len(bs), w.baseLen)
+}
+
+func fnBenchmarkReuseDecode(b *testing.B, bc *benchChecker, w *benchWorkload, s benchStrategy) {
+	defer benchRecoverPanic(b)
+	// decode the same bytes as fnBenchmarkDecode
+	buf, err := benchDecodeEncodeFn(bc)(w.v, nil)
+	if err != nil {
+		b.Logf("Error encoding %T: %s: %v", w.v, bc.name, err)
+		b.FailNow()
+	}
+	decoders := s.decoders(bc.newdec)
+	b.ReportAllocs()
+	if testv.UseParallel {
+		fnBenchmarkRunParallel(b, func() func() error {
+			decfn := decoders()
+			newfn := w.newReusable()
+			return func() error {
+				return decfn(buf, newfn())
+			}
+		})
+	} else {
+		decfn := decoders()
+		fnBenchmarkRun(b, func() {
+			if err = decfn(buf, w.newfn()); err != nil {
+				b.Logf("Error decoding into new %T: %s (%s): %v", w.v, bc.name, s, err)
+				b.FailNow()
+			}
+		})
+	}
+	fnBenchmarkReportSize(b, len(buf), w.baseLen)
+}
+
+// TestBenchReuseCheck checks that reused encoders and decoders round-trip the workloads,
+// across repeated calls (so state is not carried over from a previous call).
+func TestBenchReuseCheck(t *testing.T) {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.newenc != nil || bc.newdec != nil }) {
+		for _, w := range benchWorkloadsSelected {
+			if bc.skipReason(w) != "" {
+				continue
+			}
+			encfn, decfn := bc.encodefn, bc.decodefn
+			if bc.newenc != nil {
+				encfn = bc.newenc()
+			}
+			if bc.newdec != nil {
+				decfn = bc.newdec()
+			}
+			for i := 0; i < 2; i++ {
+				bs, err := encfn(w.v, nil)
+				if err != nil {
+					t.Errorf("%s: %s: error encoding: %v", bc.name, w.name, err)
+					break
+				}
+				v := w.new()
+				if err = decfn(bs, v); err != nil {
+					t.Errorf("%s: %s: error decoding: %v", bc.name, w.name, err)
+					break
+				}
+				if d := testDiff(w.v, v, bc.adapted(w)&benchCapNilVsEmpty != 0); len(d) != 0 {
+					t.Errorf("%s: %s: round-trip #%d: %v", bc.name, w.name, i+1, d)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file checks how each decoder copes with hostile input: truncated and corrupted bytes.
+//
+// Each checker encodes benchTs, then decodes:
+//   - prefixes of the output: all of them, or up to -brp evenly spaced (always including the longest)
+//   - the output with a random byte flipped: -brf times, seeded by -seed (or 1)
+//
+// Each decode is classified as (see benchOutcome):
+//   - error:    an error was returned
+//   - accepted: no error, and the value round-trips (e.g. a prefix which only drops trailing whitespace)
+//   - garbage:  no error, but the value does not round-trip
+//   - panic:    the decoder panicked
+//   - hang:     the decoder did not return in benchRobustTimeout
+//
+// A truncated value should always be an error. A flipped byte may legitimately decode
+// to a different value (e.g. a flipped digit), so garbage is only a problem for prefixes.
+// Panics and hangs are always a problem; they fail the test for codec, and are listed for others.
+//
+// The table is printed, and written as Markdown to the file given by -bro (if set).
+//
+// Sample way to run:
+//    go test -tags x -run BenchRobustness -v -bro robustness.md
+//    go test -tags x -run BenchRobustness -v -brp 0 -brf 4096 # exhaustive (slow)
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const benchRobustTimeout = 10 * time.Second
+
+// benchRobustCounts counts the decodes of a kind of input (prefix or flip) by outcome.
+type benchRobustCounts [benchOutcomeNum]int
+
+func (x *benchRobustCounts) String() string {
+	var s []string
+	for i, n := range x {
+		if n != 0 {
+			s = append(s, fmt.Sprintf("%s %d", benchOutcome(i), n))
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// benchRobustResult is the robustness of a checker, along with the first problem of each outcome.
+type benchRobustResult struct {
+	name            string
+	skip            string
+	prefixes, flips benchRobustCounts
+	details         []string
+}
+
+// benchRobustPrefixLens returns the lengths of the prefixes of an n-byte value to decode:
+// all of them, or max evenly spaced (including the longest) if max > 0.
+func benchRobustPrefixLens(n, max int) (v []int) {
+	if max <= 0 || max >= n {
+		for i := 0; i < n; i++ {
+			v = append(v, i)
+		}
+		return
+	}
+	for i := 0; i < max; i++ {
+		v = append(v, (n-1)-i*(n-1)/max)
+	}
+	return
+}
+
+// benchRobustDecodeFn returns the function which decodes bytes for bc.
+// For codec, this uses Decode (not MustDecode, which the benchmarks use and which panics by design).
+func benchRobustDecodeFn(bc *benchChecker) benchDecFn {
+	if bc.group != benchGroupCodec {
+		return bc.decodefn
+	}
+	h := benchCodecHandle(bc.format)
+	return func(buf []byte, ts interface{}) error {
+		return testSharedCodecDecode(buf, ts, h, false)
+	}
+}
+
+// benchRobustDecode decodes bs with decfn, and classifies the result.
+func benchRobustDecode(bc *benchChecker, decfn benchDecFn, w *benchWorkload, bs []byte) (o benchOutcome, detail string) {
+	v := w.new()
+	switch o, _, detail = benchGuard(benchRobustTimeout, func() error { return decfn(bs, v) }); o {
+	case benchOutcomeError:
+		return o, ""
+	case benchOutcomeAccepted:
+		if d := testDiff(w.v, v, bc.adapted(w)&benchCapNilVsEmpty != 0); len(d) != 0 {
+			return benchOutcomeGarbage, d.summary() + ": " + d[0].String()
+		}
+	}
+	return
+}
+
+func benchRobustCheck(bc *benchChecker, w *benchWorkload, rng *rand.Rand) (res benchRobustResult) {
+	res.name = bc.name
+	if res.skip = bc.skipReason(w); res.skip != "" {
+		return
+	}
+	bs, err := bc.encodefn(w.v, nil)
+	if err != nil {
+		res.skip = "error encoding: " + err.Error()
+		return
+	}
+	bs = append([]byte(nil), bs...) // some encoders return bytes only valid until their next call
+	decfn := benchRobustDecodeFn(bc)
+	seen := make(map[string]bool)
+	record := func(counts *benchRobustCounts, kind string, o benchOutcome, detail string) {
+		counts[o]++
+		// keep the first of each problem, by kind of input
+		key := strings.Fields(kind)[0] + " " + o.String()
+		if detail != "" && !seen[key] {
+			seen[key] = true
+			res.details = append(res.details, fmt.Sprintf("%s: %s: %s", kind, o, benchInteropTrim(detail)))
+		}
+	}
+	for _, n := range benchRobustPrefixLens(len(bs), testv.BenchmarkRobustPrefixes) {
+		o, detail := benchRobustDecode(bc, decfn, w, bs[:n:n])
+		record(&res.prefixes, fmt.Sprintf("prefix %d/%d", n, len(bs)), o, detail)
+	}
+	flipped := make([]byte, len(bs))
+	for i := 0; i < testv.BenchmarkRobustFlips && len(bs) > 0; i++ {
+		copy(flipped, bs)
+		pos, mask := rng.Intn(len(bs)), byte(1+rng.Intn(255))
+		flipped[pos] ^= mask
+		o, detail := benchRobustDecode(bc, decfn, w, flipped)
+		if o == benchOutcomeGarbage {
+			detail = "" // expected of some flips e.g. of a digit
+		}
+		record(&res.flips, fmt.Sprintf("flip %#02x at %d", mask, pos), o, detail)
+	}
+	return
+}
+
+// benchRobustWriteMarkdown writes the results as a Markdown table.
+func benchRobustWriteMarkdown(w io.Writer, results []benchRobustResult) {
+	fmt.Fprintf(w, "| Library | Truncated prefixes | Byte flips | Notes |\n|---|---|---|---|\n")
+	for _, r := range results {
+		if r.skip != "" {
+			fmt.Fprintf(w, "| %s | | | skipped: %s |\n", r.name, r.skip)
+			continue
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s |\n", r.name, &r.prefixes, &r.flips,
+			strings.ReplaceAll(strings.Join(r.details, "; "), "|", `\|`))
+	}
+}
+
+func TestBenchRobustness(t *testing.T) {
+	seed := testv.BenchmarkSeed
+	if seed == 0 {
+		seed = 1
+	}
+	w := benchWorkloadFind(benchWorkloadDefault)
+	var results []benchRobustResult
+	tw := benchNewOutcomeTable()
+	fmt.Fprintf(tw, "checker\tprefixes\tflips\t\n")
+	for _, bc := range benchCheckersFor(nil) {
+		// each checker gets its own generator, so its flips do not depend on which others are registered
+		res := benchRobustCheck(bc, w, rand.New(rand.NewSource(seed)))
+		results = append(results, res)
+		if res.skip != "" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", res.name, &res.prefixes, &res.flips)
+		if bc.group == benchGroupCodec {
+			for _, c := range [...]*benchRobustCounts{&res.prefixes, &res.flips} {
+				if c[benchOutcomePanic] != 0 || c[benchOutcomeHang] != 0 {
+					t.Errorf("%s: %s", bc.name, strings.Join(res.details, "; "))
+				}
+			}
+		}
+		for _, s := range res.details {
+			tw.note("%s: %s", res.name, s)
+		}
+	}
+	tw.flush()
+	if testv.BenchmarkRobustOutput != "" {
+		var buf bytes.Buffer
+		benchRobustWriteMarkdown(&buf, results)
+		if err := os.WriteFile(testv.BenchmarkRobustOutput, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
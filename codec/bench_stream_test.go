@@ -0,0 +1,189 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines streaming benchmarks, which encode N records (-bsn) back-to-back
+// to one io.Writer, and decode them one at a time from one io.Reader,
+// using a single encoder (or decoder) per stream (see benchChecker.streamenc).
+//
+// Each library uses its own stream framing:
+//   - json: NDJSON i.e. each record followed by a newline
+//   - msgpack, binc, simple: concatenated records
+//   - cbor: a CBOR sequence (RFC 8742) i.e. concatenated records
+//   - gob: a gob stream, where each type descriptor is sent once (before its first record)
+//   - xml: concatenated elements
+//
+// Each library decodes the stream it encoded, as framings (and gob streams especially) differ.
+//
+// Sample way to run:
+//    go test -tags x -run XXX -bench '__Stream' -bsn 1000
+//
+// Benchmarks are named after the checker e.g. Benchmark__StreamEncode/Gob.
+// Each op encodes (or decodes) a whole stream, and ns/record and bytes/record
+// are reported in addition to the metrics reported by every benchmark (see fnBenchmarkReportSize).
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+var benchNewline = []byte{'\n'}
+
+func Benchmark__StreamEncode(b *testing.B) {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.streamenc != nil }) {
+		b.Run(bc.title, func(b *testing.B) {
+			bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+				fnBenchmarkStreamEncode(b, bc, w, testv.BenchmarkStreamRecords)
+			})
+		})
+	}
+}
+
+func Benchmark__StreamDecode(b *testing.B) {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.streamdec != nil }) {
+		b.Run(bc.title, func(b *testing.B) {
+			bc.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+				fnBenchmarkStreamDecode(b, bc, w, testv.BenchmarkStreamRecords)
+			})
+		})
+	}
+}
+
+// benchStreamable returns whether the checker can both encode and decode streams.
+func benchStreamable(bc *benchChecker) bool {
+	return bc.streamenc != nil && bc.streamdec != nil
+}
+
+// benchStreamEncode writes n records of v to buf (after truncating it), using a new stream encoder.
+func benchStreamEncode(buf *bytes.Buffer, streamenc func(io.Writer) benchStreamFn, v interface{}, n int) error {
+	buf.Reset()
+	encfn := streamenc(buf)
+	for i := 0; i < n; i++ {
+		if err := encfn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// benchStreamBaselineLen returns the length of a stream of n records of the workload's value
+// encoded by the baseline, or 0 if the baseline is not registered or cannot handle it.
+func benchStreamBaselineLen(w *benchWorkload, n int) int {
+	for _, bc := range benchCheckersFor(func(bc *benchChecker) bool { return bc.name == benchBaseline }) {
+		if bc.skipReason(w) != "" || bc.streamenc == nil {
+			return 0
+		}
+		var buf bytes.Buffer
+		if benchStreamEncode(&buf, bc.streamenc, w.v, n) == nil {
+			return buf.Len()
+		}
+	}
+	return 0
+}
+
+func fnBenchmarkStreamEncode(b *testing.B, bc *benchChecker, w *benchWorkload, n int) {
+	defer benchRecoverPanic(b)
+	var buf bytes.Buffer
+	fnBenchmarkRun(b, func() {
+		if err := benchStreamEncode(&buf, bc.streamenc, w.v, n); err != nil {
+			b.Logf("Error encoding stream of %d %T: %s: %v", n, w.v, bc.name, err)
+			b.FailNow()
+		}
+	})
+	fnBenchmarkReportSize(b, buf.Len(), benchStreamBaselineLen(w, n))
+	benchStreamReport(b, buf.Len(), n)
+}
+
+func fnBenchmarkStreamDecode(b *testing.B, bc *benchChecker, w *benchWorkload, n int) {
+	defer benchRecoverPanic(b)
+	var buf bytes.Buffer
+	if err := benchStreamEncode(&buf, bc.streamenc, w.v, n); err != nil {
+		b.Logf("Error encoding stream of %d %T: %s: %v", n, w.v, bc.name, err)
+		b.FailNow()
+	}
+	bs := buf.Bytes()
+	r := bytes.NewReader(nil)
+	fnBenchmarkRun(b, func() {
+		r.Reset(bs)
+		decfn := bc.streamdec(r)
+		for i := 0; i < n; i++ {
+			if err := decfn(w.newfn()); err != nil {
+				b.Logf("Error decoding record %d of stream into new %T: %s: %v", i, w.v, bc.name, err)
+				b.FailNow()
+			}
+		}
+	})
+	fnBenchmarkReportSize(b, len(bs), benchStreamBaselineLen(w, n))
+	benchStreamReport(b, len(bs), n)
+}
+
+// benchStreamReport reports the time and encoded bytes per record, for streams of n records.
+func benchStreamReport(b *testing.B, size, n int) {
+	if b.N == 0 || n == 0 {
+		return
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(n), "ns/record")
+	b.ReportMetric(float64(size)/float64(n), "bytes/record")
+}
+
+// TestBenchStreamCheck checks that streams round-trip the workloads, record by record,
+// and that the stream ends after the last record.
+func TestBenchStreamCheck(t *testing.T) {
+	for _, bc := range benchCheckersFor(benchStreamable) {
+		for _, w := range benchWorkloadsSelected {
+			if bc.skipReason(w) == "" {
+				benchStreamCheck(t, bc, w, 3, nil)
+			}
+		}
+	}
+}
+
+// benchStreamCheck checks that a stream of n records of the workload's value round-trips,
+// writing and reading through wrap if non-nil.
+func benchStreamCheck(t *testing.T, bc *benchChecker, w *benchWorkload, n int, wrap *testIOWrap) {
+	if _, err := benchStreamRoundTrip(bc, w, n, wrap); err != nil {
+		t.Error(err)
+	}
+}
+
+// benchStreamRoundTrip round-trips a stream of n records of the workload's value,
+// writing and reading through wrap if non-nil. It returns the first failure,
+// along with the decoded value if the failure is that it differs (as a testMismatchReport).
+func benchStreamRoundTrip(bc *benchChecker, w *benchWorkload, n int, wrap *testIOWrap) (v interface{}, err error) {
+	name := bc.name + ": " + w.name
+	var buf bytes.Buffer
+	var wr io.Writer = &buf
+	if wrap != nil {
+		wr, name = wrap.writer(&buf), name+": "+wrap.String()
+	}
+	encfn := bc.streamenc(wr)
+	for i := 0; i < n; i++ {
+		if err = encfn(w.v); err != nil {
+			return nil, fmt.Errorf("%s: error encoding record %d: %w", name, i, err)
+		}
+	}
+	var r io.Reader = &buf
+	if wrap != nil {
+		r = wrap.reader(&buf)
+	}
+	decfn := bc.streamdec(r)
+	for i := 0; i < n; i++ {
+		v = w.new()
+		if err = decfn(v); err != nil {
+			return nil, fmt.Errorf("%s: error decoding record %d: %w", name, i, err)
+		}
+		if d := testDiff(w.v, v, bc.adapted(w)&benchCapNilVsEmpty != 0); len(d) != 0 {
+			return v, fmt.Errorf("%s: record %d: %w", name, i, d)
+		}
+	}
+	if err = decfn(w.new()); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: expected io.EOF after %d records, got: %v", name, n, err)
+	}
+	return nil, nil
+}
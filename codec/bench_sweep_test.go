@@ -0,0 +1,125 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines Benchmark__Sweep, which runs every checker across a grid
+// of depths (-bsd) and string repeat factors (-bsr), so we can see where the
+// performance of each library falls off as payloads grow.
+//
+// Sample way to run:
+//    go test -tags x -run XXX -bench __Sweep -bsd 0,1,2 -bsr 1,32 > sweep.txt
+//    go run ../cmd/benchreport -o report sweep.txt
+//
+// Each benchmark is named after its grid point, operation and checker
+// e.g. Benchmark__Sweep/bd1-trs8/Encode/Json (followed by the workload, when not only teststruc),
+// and reports ns/byte (time per encoded byte) in addition to the metrics
+// reported by every benchmark (see fnBenchmarkReportSize) e.g. encoded-bytes.
+//
+// Note that depth above 1 likely triggers stack growth in encoders (see testv.Depth).
+// That is part of what the sweep measures.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchSweepPoint is a point in the grid swept by Benchmark__Sweep.
+type benchSweepPoint struct {
+	depth  int // see -bd
+	repeat int // see -trs
+}
+
+func (x benchSweepPoint) String() string {
+	return "bd" + strconv.Itoa(x.depth) + "-trs" + strconv.Itoa(x.repeat)
+}
+
+// benchSweepGrid returns the grid of points from comma-separated depths and repeat factors,
+// ordered by depth, then repeat factor.
+func benchSweepGrid(depths, repeats string) (v []benchSweepPoint, err error) {
+	ds, err := benchSweepInts(depths)
+	if err != nil {
+		return
+	}
+	rs, err := benchSweepInts(repeats)
+	if err != nil {
+		return
+	}
+	for _, d := range ds {
+		for _, r := range rs {
+			v = append(v, benchSweepPoint{depth: d, repeat: r})
+		}
+	}
+	return
+}
+
+func benchSweepInts(s string) (v []int, err error) {
+	for _, f := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid sweep value: %q in %q", f, s)
+		}
+		v = append(v, i)
+	}
+	return
+}
+
+func Benchmark__Sweep(b *testing.B) {
+	grid, err := benchSweepGrid(testv.BenchmarkSweepDepths, testv.BenchmarkSweepRepeats)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func(depth, repeat int) {
+		testv.Depth, testv.NumRepeatString = depth, repeat
+		benchInitValues()
+	}(testv.Depth, testv.NumRepeatString)
+	for _, p := range grid {
+		testv.Depth, testv.NumRepeatString = p.depth, p.repeat
+		benchInitValues()
+		b.Run(p.String(), func(b *testing.B) {
+			b.Run("Encode", func(b *testing.B) {
+				for _, bc := range benchCheckersFor(nil) {
+					if bc.runnable() {
+						b.Run(bc.title, bc.benchSweepEncode)
+					}
+				}
+			})
+			b.Run("Decode", func(b *testing.B) {
+				for _, bc := range benchCheckersFor(nil) {
+					if bc.runnable() {
+						b.Run(bc.title, bc.benchSweepDecode)
+					}
+				}
+			})
+		})
+	}
+}
+
+func (x *benchChecker) benchSweepEncode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		b.ReportAllocs()
+		fnBenchmarkEncode(b, x.name, w.v, x.encodefn, w.baseLen)
+		benchSweepReport(b, w.v, x.encodefn)
+	})
+}
+
+func (x *benchChecker) benchSweepDecode(b *testing.B) {
+	x.benchWorkloads(b, func(b *testing.B, w *benchWorkload) {
+		b.ReportAllocs()
+		fnBenchmarkDecode(b, x.name, w.v, benchDecodeEncodeFn(x), x.decodefn, w.newfn, w.baseLen)
+		benchSweepReport(b, w.v, benchDecodeEncodeFn(x))
+	})
+}
+
+// benchSweepReport reports the time per byte of v encoded by encfn.
+func benchSweepReport(b *testing.B, v interface{}, encfn benchEncFn) {
+	bs, err := encfn(v, nil)
+	if err != nil || len(bs) == 0 || b.N == 0 {
+		return
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(len(bs)), "ns/byte")
+}
@@ -0,0 +1,333 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines the catalog of workloads i.e. named shapes of data
+// which every registered benchChecker is benchmarked against.
+//
+// TestStruc (the teststruc workload) is a kitchen-sink shape, which exercises
+// many features, but does not look like typical traffic.
+// The other workloads each focus on a single shape.
+//
+// Select the workloads using -bw e.g.
+//    go test -tags x -run BenchOnePassCheck -bw small,numeric
+//    go test -tags x -bench __Encode -bw all
+//
+// When only teststruc is selected (the default), benchmark names are unchanged.
+// Otherwise, each workload runs as a sub-benchmark named after it
+// e.g. Benchmark__Encode/Json/small.
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const benchWorkloadDefault = "teststruc"
+
+// benchWorkload is a named shape of data used in benchmarks.
+type benchWorkload struct {
+	name string
+	desc string
+
+	// populate returns a pointer to a new populated value.
+	// depth and repeat come from the -bd and -trs flags.
+	populate func(depth, repeat int) interface{}
+
+	// caps returns the capabilities required to handle the populated value (see benchCap).
+	caps func(depth int) benchCap
+
+	// norandom keeps the populated values with -seed e.g. for a corpus, whose values are realistic.
+	norandom bool
+
+	// v, vcaps, typ and newfn are set during benchInit.
+	v     interface{}
+	vcaps benchCap
+	typ   reflect.Type
+	newfn benchIntfFn // returns a pointer to a zero value, reused across calls
+
+	// baseLen is the length of v encoded by the baseline (see benchBaselineLen), set during benchInit.
+	baseLen int
+}
+
+// new returns a pointer to a new zero value, for decoding into.
+func (x *benchWorkload) new() interface{} {
+	return reflect.New(x.typ).Interface()
+}
+
+func (x *benchWorkload) init() {
+	x.v = x.populate(testv.Depth, testv.NumRepeatString)
+	x.vcaps = x.caps(testv.Depth)
+	if testv.BenchmarkSeed != 0 && !x.norandom {
+		// each workload gets its own generator, so its values do not depend on which others are selected
+		var maxUint64 uint64 = math.MaxInt64
+		if x.vcaps&benchCapUint64AboveMaxInt64 != 0 {
+			maxUint64 = math.MaxUint64
+		}
+		testRandomize(x.v, rand.New(rand.NewSource(testv.BenchmarkSeed)), maxUint64)
+	}
+	x.typ = reflect.TypeOf(x.v).Elem()
+	if x.name == benchWorkloadDefault {
+		x.newfn = fnBenchNewTs
+		return
+	}
+	x.newfn = x.newReusable()
+}
+
+// newReusable returns a func which returns a pointer to a zero value, reused across calls.
+// It is not safe for concurrent use.
+func (x *benchWorkload) newReusable() benchIntfFn {
+	rv := reflect.New(x.typ)
+	p := rv.Interface()
+	return func() interface{} {
+		rv.Elem().SetZero()
+		return p
+	}
+}
+
+var benchWorkloads = []*benchWorkload{
+	{
+		name: benchWorkloadDefault,
+		desc: "TestStruc: a kitchen-sink of scalars, slices, maps, arrays, pointers and embedded structs",
+		populate: func(depth, repeat int) interface{} {
+			return benchTs
+		},
+		caps: func(depth int) benchCap {
+			return testStrucCaps(depth, true, testv.MapStringKeyOnly)
+		},
+	},
+	{
+		name:     "small",
+		desc:     "a small flat record with a few scalar fields, like a typical API request",
+		populate: benchPopulateSmall,
+		caps:     func(depth int) benchCap { return 0 },
+	},
+	{
+		name:     "numeric",
+		desc:     "slices of integers and floats of varying magnitude and sign",
+		populate: benchPopulateNumeric,
+		caps:     func(depth int) benchCap { return benchCapNestedSlices },
+	},
+	{
+		name:     "strings",
+		desc:     "short, long, unicode and escape-heavy strings",
+		populate: benchPopulateStrings,
+		caps:     func(depth int) benchCap { return 0 },
+	},
+	{
+		name:     "nested",
+		desc:     "a tree of records, with fan-out 3 and depth -bd + 2",
+		populate: benchPopulateNested,
+		// leaves have nil Children
+		caps: func(depth int) benchCap { return benchCapStructPtrSlice | benchCapNilVsEmpty },
+	},
+	{
+		name:     "maps",
+		desc:     "maps of scalars, maps of maps and maps of records, keyed by strings",
+		populate: benchPopulateMaps,
+		caps:     func(depth int) benchCap { return benchCapMaps },
+	},
+}
+
+// benchWorkloadsSelected are the workloads selected by the -bw flag (set in benchInit).
+var benchWorkloadsSelected []*benchWorkload
+
+func benchWorkloadFind(name string) *benchWorkload {
+	for _, w := range benchWorkloads {
+		if w.name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// benchWorkloadsSelect returns the workloads named in the comma-separated list s.
+// The name "all" selects all workloads.
+func benchWorkloadsSelect(s string) (v []*benchWorkload, err error) {
+	for _, n := range strings.Split(s, ",") {
+		n = strings.TrimSpace(n)
+		if n == "all" {
+			return benchWorkloads, nil
+		}
+		w := benchWorkloadFind(n)
+		if w == nil {
+			var names []string
+			for _, w := range benchWorkloads {
+				names = append(names, w.name)
+			}
+			return nil, fmt.Errorf("unknown workload: %q (supported: all, %s)", n, strings.Join(names, ", "))
+		}
+		v = append(v, w)
+	}
+	return
+}
+
+// benchWorkloadsIsDefault returns true if only the default workload (teststruc) is selected.
+func benchWorkloadsIsDefault() bool {
+	return len(benchWorkloadsSelected) == 1 && benchWorkloadsSelected[0].name == benchWorkloadDefault
+}
+
+// ---- small ----
+
+type benchSmall struct {
+	ID      int64
+	Name    string
+	Email   string
+	Active  bool
+	Score   float64
+	Age     uint8
+	Tags    []string
+	Created int64 // unix seconds
+}
+
+func benchNewSmall(i int) benchSmall {
+	return benchSmall{
+		ID:      int64(1000 + i),
+		Name:    "user-" + strconv.Itoa(i),
+		Email:   "user" + strconv.Itoa(i) + "@example.com",
+		Active:  i%2 == 0,
+		Score:   float64(i) * 1.25,
+		Age:     uint8(18 + i%60),
+		Tags:    []string{"alpha", "beta", "gamma"}[:1+i%3],
+		Created: 1577836800 + int64(i)*3600,
+	}
+}
+
+func benchPopulateSmall(depth, repeat int) interface{} {
+	v := benchNewSmall(7)
+	return &v
+}
+
+// ---- numeric ----
+
+type benchNumeric struct {
+	I64    []int64
+	U64    []uint64
+	I32    []int32
+	U16    []uint16
+	F64    []float64
+	F32    []float32
+	Matrix [][]float64
+}
+
+func benchPopulateNumeric(depth, repeat int) interface{} {
+	const n = 64
+	v := &benchNumeric{
+		I64:    make([]int64, n),
+		U64:    make([]uint64, n),
+		I32:    make([]int32, n),
+		U16:    make([]uint16, n),
+		F64:    make([]float64, n),
+		F32:    make([]float32, n),
+		Matrix: make([][]float64, 8),
+	}
+	for i := 0; i < n; i++ {
+		// spread values across small and large magnitudes, so varint encodings vary in length
+		sign := int64(1 - 2*(i%2))
+		v.I64[i] = sign * (int64(1) << uint(i%63))
+		v.U64[i] = uint64(1)<<uint(i%63) + uint64(i)
+		v.I32[i] = int32(sign) * int32(i*i*i*997%math.MaxInt32)
+		v.U16[i] = uint16(i * 1031)
+		v.F64[i] = float64(sign) * math.Pow(10, float64(i%16-8)) * (1 + float64(i)/n)
+		v.F32[i] = float32(i) / 8
+	}
+	for i := range v.Matrix {
+		v.Matrix[i] = make([]float64, 8)
+		for j := range v.Matrix[i] {
+			v.Matrix[i][j] = float64(i*8+j) / 4
+		}
+	}
+	return v
+}
+
+// ---- strings ----
+
+type benchStrings struct {
+	Short   []string
+	Long    []string
+	Unicode []string
+	Escaped []string
+}
+
+func benchPopulateStrings(depth, repeat int) interface{} {
+	v := &benchStrings{
+		Short: []string{"", "a", "id", "name", "value", "created_at", "2006-01-02T15:04:05Z"},
+		Unicode: []string{
+			"héllo wörld", "日本語のテキスト", "Ελληνικά", "emoji: 😀🎉🚀", "mixed ascii and 中文 text",
+		},
+		Escaped: []string{
+			`quote: "hello"`, `backslash: C:\path\to\file`, "tab:\tnewline:\n",
+			"<html> & 'entities' </html>", `json: {"a":[1,2,3]}`,
+		},
+	}
+	if repeat < 1 {
+		repeat = 1
+	}
+	for i := 0; i < 4; i++ {
+		v.Long = append(v.Long, strings.Repeat(testLongSentence, repeat*(i+1)))
+	}
+	return v
+}
+
+// ---- nested ----
+
+type benchNested struct {
+	Name     string
+	Value    int64
+	Weight   float64
+	Children []*benchNested
+}
+
+func benchNewNested(name string, depth int) *benchNested {
+	v := &benchNested{Name: name, Value: int64(len(name) * depth), Weight: float64(depth) / 2}
+	if depth > 0 {
+		for i := 0; i < 3; i++ {
+			v.Children = append(v.Children, benchNewNested(name+"."+strconv.Itoa(i), depth-1))
+		}
+	}
+	return v
+}
+
+func benchPopulateNested(depth, repeat int) interface{} {
+	return benchNewNested("root", depth+2)
+}
+
+// ---- maps ----
+
+type benchMaps struct {
+	Counts  map[string]int64
+	Labels  map[string]string
+	Nested  map[string]map[string]float64
+	Records map[string]benchSmall
+}
+
+func benchPopulateMaps(depth, repeat int) interface{} {
+	const n = 16
+	v := &benchMaps{
+		Counts:  make(map[string]int64, n),
+		Labels:  make(map[string]string, n),
+		Nested:  make(map[string]map[string]float64, 4),
+		Records: make(map[string]benchSmall, 4),
+	}
+	for i := 0; i < n; i++ {
+		k := "key-" + strconv.Itoa(i)
+		v.Counts[k] = int64(i * i)
+		v.Labels[k] = "label value " + strconv.Itoa(i)
+	}
+	for i := 0; i < 4; i++ {
+		k := "group-" + strconv.Itoa(i)
+		m := make(map[string]float64, 4)
+		for j := 0; j < 4; j++ {
+			m["metric-"+strconv.Itoa(j)] = float64(i*4+j) / 8
+		}
+		v.Nested[k] = m
+		v.Records[k] = benchNewSmall(i)
+	}
+	return v
+}
@@ -6,24 +6,177 @@
 package codec
 
 import (
-	"testing"
+	"io"
+
+	. "github.com/ugorji/go/codec"
 )
 
 func init() {
 	testPreInitFns = append(testPreInitFns, codecBenchPreInit)
-	// testPostInitFns = append(testPostInitFns, codecbenchPostInit)
+	testPostInitFns = append(testPostInitFns, codecBenchDetInit)
+	testReInitFns = append(testReInitFns, codecBenchDetInit)
+}
+
+// benchCodecDetHandles are the Handles with Canonical set, used by benchCodecDetEnc.
+// They are re-created (with the shared ones) on each reinit, so they track the options.
+var benchCodecDetHandles map[benchFormat]Handle
+
+func codecBenchDetInit() {
+	benchCodecDetHandles = make(map[benchFormat]Handle)
+	for _, f := range [...]benchFormat{benchFormatMsgpack, benchFormatBinc, benchFormatSimple, benchFormatCbor, benchFormatJson} {
+		benchCodecDetHandles[f] = benchCodecNewHandle(f, func(bh *BasicHandle) { bh.Canonical = true })
+	}
 }
 
 func codecBenchPreInit() {
 	benchCheckers = append(benchCheckers,
-		benchChecker{"msgpack", fnMsgpackEncodeFn, fnMsgpackDecodeFn},
-		benchChecker{"binc", fnBincEncodeFn, fnBincDecodeFn},
-		benchChecker{"simple", fnSimpleEncodeFn, fnSimpleDecodeFn},
-		benchChecker{"cbor", fnCborEncodeFn, fnCborDecodeFn},
-		benchChecker{"json", fnJsonEncodeFn, fnJsonDecodeFn},
+		benchChecker{name: "msgpack", title: "Msgpack", format: benchFormatMsgpack, group: benchGroupCodec,
+			encodefn: fnMsgpackEncodeFn, decodefn: fnMsgpackDecodeFn, caps: benchCapAll,
+			newenc: benchCodecNewEnc(benchFormatMsgpack), newdec: benchCodecNewDec(benchFormatMsgpack),
+			streamenc: benchCodecStreamEnc(benchFormatMsgpack), streamdec: benchCodecStreamDec(benchFormatMsgpack),
+			detenc: benchCodecDetEnc(benchFormatMsgpack)},
+		benchChecker{name: "binc", title: "Binc", format: benchFormatBinc, group: benchGroupCodec,
+			encodefn: fnBincEncodeFn, decodefn: fnBincDecodeFn, caps: benchCapAll,
+			newenc: benchCodecNewEnc(benchFormatBinc), newdec: benchCodecNewDec(benchFormatBinc),
+			streamenc: benchCodecStreamEnc(benchFormatBinc), streamdec: benchCodecStreamDec(benchFormatBinc),
+			detenc: benchCodecDetEnc(benchFormatBinc)},
+		benchChecker{name: "simple", title: "Simple", format: benchFormatSimple, group: benchGroupCodec,
+			encodefn: fnSimpleEncodeFn, decodefn: fnSimpleDecodeFn, caps: benchCapAll,
+			newenc: benchCodecNewEnc(benchFormatSimple), newdec: benchCodecNewDec(benchFormatSimple),
+			streamenc: benchCodecStreamEnc(benchFormatSimple), streamdec: benchCodecStreamDec(benchFormatSimple),
+			detenc: benchCodecDetEnc(benchFormatSimple)},
+		benchChecker{name: "cbor", title: "Cbor", format: benchFormatCbor, group: benchGroupCodec,
+			encodefn: fnCborEncodeFn, decodefn: fnCborDecodeFn, caps: benchCapAll,
+			newenc: benchCodecNewEnc(benchFormatCbor), newdec: benchCodecNewDec(benchFormatCbor),
+			streamenc: benchCodecStreamEnc(benchFormatCbor), streamdec: benchCodecStreamDec(benchFormatCbor),
+			detenc: benchCodecDetEnc(benchFormatCbor)},
+		benchChecker{name: "json", title: "Json", format: benchFormatJson, group: benchGroupCodec,
+			encodefn: fnJsonEncodeFn, decodefn: fnJsonDecodeFn, caps: benchCapAll,
+			newenc: benchCodecNewEnc(benchFormatJson), newdec: benchCodecNewDec(benchFormatJson),
+			streamenc: benchCodecStreamEnc(benchFormatJson), streamdec: benchCodecStreamDec(benchFormatJson),
+			detenc: benchCodecDetEnc(benchFormatJson)},
 	)
 }
 
+// benchCodecHandle returns the handle for the format.
+//
+// The handle is gotten when called, as the handles are re-created on each reinit.
+func benchCodecHandle(f benchFormat) Handle {
+	switch f {
+	case benchFormatMsgpack:
+		return testMsgpackH
+	case benchFormatBinc:
+		return testBincH
+	case benchFormatSimple:
+		return testSimpleH
+	case benchFormatCbor:
+		return testCborH
+	case benchFormatJson:
+		return testJsonH
+	}
+	return nil
+}
+
+// benchCodecNewHandle returns a new Handle for the format, built as the shared ones are
+// (by doTestInit, doTestPostInit and benchUpdateHandles), then updated by fn (if set) e.g. to set its MaxDepth.
+//
+// The shared handles are restored after, so they are not changed.
+func benchCodecNewHandle(f benchFormat, fn func(bh *BasicHandle)) (h Handle) {
+	defer func(bincH *BincHandle, msgpackH *MsgpackHandle, jsonH *JsonHandle, simpleH *SimpleHandle, cborH *CborHandle,
+		handles []Handle, heds []testHED) {
+		testBincH, testMsgpackH, testJsonH, testSimpleH, testCborH = bincH, msgpackH, jsonH, simpleH, cborH
+		testHandles, testHEDs = handles, heds
+	}(testBincH, testMsgpackH, testJsonH, testSimpleH, testCborH, testHandles, testHEDs)
+	doTestInit()
+	doTestPostInit()
+	benchUpdateHandles()
+	var bh *BasicHandle
+	switch x := benchCodecHandle(f).(type) {
+	case *MsgpackHandle:
+		h, bh = x, &x.BasicHandle
+	case *BincHandle:
+		h, bh = x, &x.BasicHandle
+	case *SimpleHandle:
+		h, bh = x, &x.BasicHandle
+	case *CborHandle:
+		h, bh = x, &x.BasicHandle
+	case *JsonHandle:
+		h, bh = x, &x.BasicHandle
+	default:
+		return nil
+	}
+	if fn != nil {
+		fn(bh)
+	}
+	return
+}
+
+// benchCodecDetEnc returns a benchChecker.detenc, which encodes with the Canonical Handle
+// for the format (map keys sorted).
+func benchCodecDetEnc(f benchFormat) benchEncFn {
+	return func(ts interface{}, bsIn []byte) ([]byte, error) {
+		return testSharedCodecEncode(ts, bsIn, fnBenchmarkByteBuf, benchCodecDetHandles[f], true)
+	}
+}
+
+// benchCodecNewEnc returns a benchChecker.newenc, whose functions use their own Encoder
+// (reset on each call) with the shared Handle.
+func benchCodecNewEnc(f benchFormat) func() benchEncFn {
+	return func() benchEncFn {
+		var e *Encoder
+		if h := benchCodecHandle(f); testUseIO() {
+			e = NewEncoder(nil, h)
+		} else {
+			e = NewEncoderBytes(nil, h)
+		}
+		return func(ts interface{}, bsIn []byte) ([]byte, error) {
+			return testCodecEncode(e, ts, bsIn, fnBenchmarkByteBuf, true)
+		}
+	}
+}
+
+// benchCodecNewDec returns a benchChecker.newdec, whose functions use their own Decoder
+// (reset on each call) with the shared Handle.
+func benchCodecNewDec(f benchFormat) func() benchDecFn {
+	return func() benchDecFn {
+		var d *Decoder
+		if h := benchCodecHandle(f); testUseIO() {
+			d = NewDecoder(nil, h)
+		} else {
+			d = NewDecoderBytes(nil, h)
+		}
+		return func(buf []byte, ts interface{}) error {
+			testCodecDecoderReset(d, buf)
+			return testCodecDecode(d, ts, true)
+		}
+	}
+}
+
+// benchCodecStreamEnc returns a benchChecker.streamenc, whose functions encode records
+// using a single Encoder with the shared Handle.
+//
+// Each Encode flushes, so json records are followed by a newline written directly to w (NDJSON).
+// Records in the binary formats are concatenated (for cbor, a CBOR sequence).
+func benchCodecStreamEnc(f benchFormat) func(io.Writer) benchStreamFn {
+	return func(w io.Writer) benchStreamFn {
+		e := NewEncoder(w, benchCodecHandle(f))
+		return func(v interface{}) (err error) {
+			if err = e.Encode(v); err == nil && f == benchFormatJson {
+				_, err = w.Write(benchNewline)
+			}
+			return
+		}
+	}
+}
+
+// benchCodecStreamDec returns a benchChecker.streamdec, whose functions decode records
+// using a single Decoder with the shared Handle.
+func benchCodecStreamDec(f benchFormat) func(io.Reader) benchStreamFn {
+	return func(r io.Reader) benchStreamFn {
+		return NewDecoder(r, benchCodecHandle(f)).Decode
+	}
+}
+
 // ------------ tests below
 
 func fnMsgpackEncodeFn(ts interface{}, bsIn []byte) (bs []byte, err error) {
@@ -65,47 +218,3 @@ func fnJsonEncodeFn(ts interface{}, bsIn []byte) (bs []byte, err error) {
 func fnJsonDecodeFn(buf []byte, ts interface{}) error {
 	return testSharedCodecDecode(buf, ts, testJsonH, true)
 }
-
-// ----------- ENCODE ------------------
-
-func Benchmark__Msgpack____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "msgpack", benchTs, fnMsgpackEncodeFn)
-}
-
-func Benchmark__Binc_______Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "binc", benchTs, fnBincEncodeFn)
-}
-
-func Benchmark__Simple_____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "simple", benchTs, fnSimpleEncodeFn)
-}
-
-func Benchmark__Cbor_______Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "cbor", benchTs, fnCborEncodeFn)
-}
-
-func Benchmark__Json_______Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "json", benchTs, fnJsonEncodeFn)
-}
-
-// ----------- DECODE ------------------
-
-func Benchmark__Msgpack____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "msgpack", benchTs, fnMsgpackEncodeFn, fnMsgpackDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Binc_______Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "binc", benchTs, fnBincEncodeFn, fnBincDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Simple_____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "simple", benchTs, fnSimpleEncodeFn, fnSimpleDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Cbor_______Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "cbor", benchTs, fnCborEncodeFn, fnCborDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Json_______Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "json", benchTs, fnJsonEncodeFn, fnJsonDecodeFn, fnBenchNewTs)
-}
@@ -0,0 +1,96 @@
+//go:build !codec.nobench && !nobench && go1.24
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines fuzz targets for the decoders of each codec Handle,
+// as they parse untrusted input.
+//
+// Each target is seeded with the encodings of benchTs and a TestStrucPlus,
+// and decodes its input into a TestStruc and into an interface{}.
+// The decoder must return an error or succeed (and not panic or hang).
+// If it succeeds, the decoded value must round-trip i.e. encoding it and decoding that
+// must give back the same value.
+//
+// Without -fuzz, the targets only run their seeds (and any inputs in testdata/fuzz),
+// as part of go test.
+//
+// Sample way to run:
+//    go test -run XXX -fuzz FuzzDecodeJson -fuzztime 1m
+//    go test -run XXX -fuzz FuzzDecodeCbor -fuzztime 1m -ti 1024 # decode through a buffered reader
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	. "github.com/ugorji/go/codec"
+)
+
+func FuzzDecodeBinc(f *testing.F)    { fuzzDecode(f, benchFormatBinc) }
+func FuzzDecodeMsgpack(f *testing.F) { fuzzDecode(f, benchFormatMsgpack) }
+func FuzzDecodeSimple(f *testing.F)  { fuzzDecode(f, benchFormatSimple) }
+func FuzzDecodeCbor(f *testing.F)    { fuzzDecode(f, benchFormatCbor) }
+func FuzzDecodeJson(f *testing.F)    { fuzzDecode(f, benchFormatJson) }
+
+// fuzzDecodeTargets returns the values decoded into by the fuzz targets.
+func fuzzDecodeTargets() []interface{} {
+	return []interface{}{new(TestStruc), new(interface{})}
+}
+
+func fuzzDecode(f *testing.F, format benchFormat) {
+	h := benchCodecHandle(format)
+	for _, v := range []interface{}{
+		benchTs,
+		newTestStrucPlus(testv.Depth, testv.NumRepeatString, true, !testv.SkipIntf, testv.MapStringKeyOnly),
+	} {
+		bs, err := testSharedCodecEncode(v, nil, fnBenchmarkByteBuf, h, false)
+		if err != nil {
+			f.Fatalf("%s: error encoding seed %T: %v", format, v, err)
+		}
+		f.Add(bs)
+	}
+	// known issue: json decodes invalid UTF-8 in strings as-is, but encodes it as U+FFFD
+	roundTrip := func(bs []byte) bool { return format != benchFormatJson || utf8.Valid(bs) }
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		for _, v := range fuzzDecodeTargets() {
+			if err := testSharedCodecDecode(bs, v, h, false); err == nil && roundTrip(bs) {
+				fuzzRoundTrip(t, h, v)
+			}
+		}
+	})
+}
+
+// fuzzRoundTrip checks that v (a pointer to a decoded value) round-trips through h.
+//
+// The input may use a form which h never encodes, so the first round-trip may change
+// the dynamic type of an interface{} (e.g. a non-negative integer in a negative-integer form
+// decodes as an int64, is re-encoded as unsigned, and then decodes as a uint64).
+// Only those type changes are allowed, and the value must not change on the next round-trip.
+func fuzzRoundTrip(t *testing.T, h Handle, v interface{}) {
+	v2 := fuzzReencode(t, h, v)
+	d := testDiff(v, v2, false)
+	for _, m := range d {
+		if m.Category != testMismatchTypeChange {
+			t.Fatalf("re-encoded %T does not round-trip: %v", v, d)
+		}
+	}
+	if d = testDiff(v2, fuzzReencode(t, h, v2), false); len(d) != 0 {
+		t.Fatalf("re-encoded %T does not round-trip (after normalizing): %v", v, d)
+	}
+}
+
+// fuzzReencode encodes v (a pointer) with h, and returns a new value of the same type decoded from it.
+func fuzzReencode(t *testing.T, h Handle, v interface{}) (v2 interface{}) {
+	bs, err := testSharedCodecEncode(v, nil, fnBenchmarkByteBuf, h, false)
+	if err != nil {
+		t.Fatalf("error re-encoding decoded %T: %v", v, err)
+	}
+	v2 = reflect.New(reflect.TypeOf(v).Elem()).Interface()
+	if err = testSharedCodecDecode(bs, v2, h, false); err != nil {
+		t.Fatalf("error decoding re-encoded %T: %v", v, err)
+	}
+	return
+}
@@ -11,7 +11,7 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"encoding/xml"
-	"testing"
+	"io"
 )
 
 func init() {
@@ -21,9 +21,19 @@ func init() {
 
 func stdlibBenchPreInit() {
 	benchCheckers = append(benchCheckers,
-		benchChecker{"std-json", fnStdJsonEncodeFn, fnStdJsonDecodeFn},
-		benchChecker{"gob", fnGobEncodeFn, fnGobDecodeFn},
-		benchChecker{"std-xml", fnStdXmlEncodeFn, fnStdXmlDecodeFn},
+		benchChecker{name: "std-json", title: "Std_Json", format: benchFormatJson, group: benchGroupStdlib,
+			encodefn: fnStdJsonEncodeFn, decodefn: fnStdJsonDecodeFn, caps: benchCapAll,
+			streamenc: fnStdJsonStreamEnc, streamdec: fnStdJsonStreamDec,
+			detenc: fnStdJsonEncodeFn}, // map keys are always sorted
+		benchChecker{name: "gob", title: "Gob", format: benchFormatGob, group: benchGroupStdlib,
+			encodefn: fnGobEncodeFn, decodefn: fnGobDecodeFn,
+			streamenc: fnGobStreamEnc, streamdec: fnGobStreamDec,
+			caps: benchCapAll &^ (benchCapNilInPtrSlice | benchCapNilVsEmpty | benchCapPtrToZero)},
+		benchChecker{name: "std-xml", title: "Std_Xml", format: benchFormatXml, group: benchGroupStdlib,
+			encodefn: fnStdXmlEncodeFn, decodefn: fnStdXmlDecodeFn,
+			streamenc: fnStdXmlStreamEnc, streamdec: fnStdXmlStreamDec,
+			caps: benchCapAll &^ (benchCapNonStringMapKeys | benchCapMaps | benchCapPtrMapValues |
+				benchCapNilInPtrSlice | benchCapNilVsEmpty | benchCapFixedArrays | benchCapNestedSlices)},
 	)
 }
 
@@ -65,30 +75,30 @@ func fnStdJsonDecodeFn(buf []byte, ts interface{}) error {
 	return json.Unmarshal(buf, ts)
 }
 
-// ----------- ENCODE ------------------
-
-func Benchmark__Std_Json___Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "std-json", benchTs, fnStdJsonEncodeFn)
+// fnGobStreamEnc returns a stream encode function using a single gob Encoder,
+// which sends each type descriptor once per stream (not once per record).
+func fnGobStreamEnc(w io.Writer) benchStreamFn {
+	return gob.NewEncoder(w).Encode
 }
 
-func Benchmark__Gob________Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "gob", benchTs, fnGobEncodeFn)
+func fnGobStreamDec(r io.Reader) benchStreamFn {
+	return gob.NewDecoder(r).Decode
 }
 
-func Benchmark__Std_Xml____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "std-xml", benchTs, fnStdXmlEncodeFn)
+func fnStdXmlStreamEnc(w io.Writer) benchStreamFn {
+	return xml.NewEncoder(w).Encode
 }
 
-// ----------- DECODE ------------------
-
-func Benchmark__Std_Json___Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "std-json", benchTs, fnStdJsonEncodeFn, fnStdJsonDecodeFn, fnBenchNewTs)
+func fnStdXmlStreamDec(r io.Reader) benchStreamFn {
+	return xml.NewDecoder(r).Decode
 }
 
-func Benchmark__Gob________Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "gob", benchTs, fnGobEncodeFn, fnGobDecodeFn, fnBenchNewTs)
+// fnStdJsonStreamEnc returns a stream encode function using a json Encoder,
+// which writes a newline after each record (NDJSON).
+func fnStdJsonStreamEnc(w io.Writer) benchStreamFn {
+	return json.NewEncoder(w).Encode
 }
 
-func Benchmark__Std_Xml____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "std-xml", benchTs, fnStdXmlEncodeFn, fnStdXmlDecodeFn, fnBenchNewTs)
+func fnStdJsonStreamDec(r io.Reader) benchStreamFn {
+	return json.NewDecoder(r).Decode
 }
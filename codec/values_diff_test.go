@@ -0,0 +1,351 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file computes a structured, field-level diff between an encoded value
+// and the value decoded from it, so we can tell at once if a mismatch matters.
+//
+// Each mismatch is reported by its field path e.g. TestStruc.NotAnon.AMSU64["1"],
+// and tagged with a category e.g. nil-vs-empty, precision-loss, etc.
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testMismatchCategory uint8
+
+const (
+	testMismatchValue           testMismatchCategory = iota // value differs (none of the categories below)
+	testMismatchNilVsEmpty                                  // nil slice/map/pointer decoded as empty, or vice versa
+	testMismatchPrecisionLoss                               // floating point value differs slightly
+	testMismatchIntegerOverflow                             // integer value wrapped or was truncated
+	testMismatchDropped                                     // value (or field) was lost i.e. decoded as zero or missing
+	testMismatchTypeChange                                  // dynamic type of an interface differs
+)
+
+func (x testMismatchCategory) String() string {
+	switch x {
+	case testMismatchValue:
+		return "value"
+	case testMismatchNilVsEmpty:
+		return "nil-vs-empty"
+	case testMismatchPrecisionLoss:
+		return "precision-loss"
+	case testMismatchIntegerOverflow:
+		return "integer-overflow"
+	case testMismatchDropped:
+		return "dropped"
+	case testMismatchTypeChange:
+		return "type-change"
+	}
+	return "unknown"
+}
+
+func (x testMismatchCategory) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// testMismatch is a single field-level difference between an expected and an actual value.
+type testMismatch struct {
+	Path     string               `json:"path"`
+	Category testMismatchCategory `json:"category"`
+	Want     string               `json:"want"`
+	Got      string               `json:"got"`
+}
+
+func (x testMismatch) String() string {
+	return fmt.Sprintf("%s: %s (want: %s, got: %s)", x.Path, x.Category, x.Want, x.Got)
+}
+
+// testMismatchReport lists all the mismatches between two values.
+// It implements error, so it can be returned where a comparison error is expected.
+type testMismatchReport []testMismatch
+
+func (x testMismatchReport) Error() string {
+	const max = 10
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d mismatch(es)", len(x))
+	for i, m := range x {
+		if i == max {
+			fmt.Fprintf(&sb, "\n\t... and %d more", len(x)-max)
+			break
+		}
+		sb.WriteString("\n\t")
+		sb.WriteString(m.String())
+	}
+	return sb.String()
+}
+
+// summary returns the count of mismatches per category e.g. "3 nil-vs-empty, 1 dropped".
+func (x testMismatchReport) summary() string {
+	var n [testMismatchTypeChange + 1]int
+	for _, v := range x {
+		n[v.Category]++
+	}
+	var s []string
+	for i, c := range n {
+		if c != 0 {
+			s = append(s, fmt.Sprintf("%d %s", c, testMismatchCategory(i)))
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// testDiff compares want and got field by field, returning all the mismatches found.
+//
+// If nilEmptyEqual, nil and empty slices/maps are considered equal.
+func testDiff(want, got interface{}, nilEmptyEqual bool) (v testMismatchReport) {
+	d := testDiffer{nilEmptyEqual: nilEmptyEqual}
+	rv1, rv2 := reflect.ValueOf(want), reflect.ValueOf(got)
+	root := "<nil>"
+	if rv1.IsValid() {
+		for rv1.Kind() == reflect.Ptr && rv2.Kind() == reflect.Ptr && !rv1.IsNil() && !rv2.IsNil() {
+			rv1, rv2 = rv1.Elem(), rv2.Elem()
+		}
+		root = rv1.Type().Name()
+	}
+	d.diff(root, rv1, rv2)
+	return d.v
+}
+
+type testDiffer struct {
+	v             testMismatchReport
+	nilEmptyEqual bool
+}
+
+func (d *testDiffer) add(path string, c testMismatchCategory, want, got reflect.Value) {
+	d.v = append(d.v, testMismatch{Path: path, Category: c, Want: testDiffFmt(want), Got: testDiffFmt(got)})
+}
+
+func testDiffFmt(rv reflect.Value) string {
+	const max = 40
+	if !rv.IsValid() {
+		return "<missing>"
+	}
+	var s string
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return "nil"
+		}
+		if rv.Len() == 0 {
+			return "empty"
+		}
+		s = fmt.Sprintf("%s len=%d", rv.Type(), rv.Len())
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return "nil"
+		}
+		s = fmt.Sprintf("%v", rv.Elem())
+	default:
+		if rv.CanInterface() {
+			s = fmt.Sprintf("%#v", rv.Interface())
+		} else {
+			s = fmt.Sprintf("%v", rv)
+		}
+	}
+	if len(s) > max {
+		s = s[:max] + "..."
+	}
+	return s
+}
+
+func (d *testDiffer) diff(path string, rv1, rv2 reflect.Value) {
+	if !rv1.IsValid() || !rv2.IsValid() {
+		if rv1.IsValid() != rv2.IsValid() {
+			d.add(path, testMismatchDropped, rv1, rv2)
+		}
+		return
+	}
+	if rv1.Type() != rv2.Type() {
+		d.add(path, testMismatchTypeChange, rv1, rv2)
+		return
+	}
+	switch rv1.Kind() {
+	case reflect.Ptr:
+		switch {
+		case rv1.IsNil() && rv2.IsNil():
+		case rv1.IsNil():
+			if rv2.Elem().IsZero() {
+				d.add(path, testMismatchNilVsEmpty, rv1, rv2)
+			} else {
+				d.add(path, testMismatchValue, rv1, rv2)
+			}
+		case rv2.IsNil():
+			d.add(path, testMismatchDropped, rv1, rv2)
+		default:
+			d.diff(path, rv1.Elem(), rv2.Elem())
+		}
+	case reflect.Interface:
+		switch {
+		case rv1.IsNil() && rv2.IsNil():
+		case rv2.IsNil():
+			d.add(path, testMismatchDropped, rv1, rv2)
+		case rv1.IsNil():
+			d.add(path, testMismatchValue, rv1, rv2)
+		case rv1.Elem().Type() != rv2.Elem().Type():
+			d.add(path, testMismatchTypeChange, rv1.Elem(), rv2.Elem())
+		default:
+			d.diff(path, rv1.Elem(), rv2.Elem())
+		}
+	case reflect.Struct:
+		for i, n := 0, rv1.NumField(); i < n; i++ {
+			if f := rv1.Type().Field(i); f.IsExported() {
+				d.diff(path+"."+f.Name, rv1.Field(i), rv2.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Map:
+		if d.diffNilEmpty(path, rv1, rv2) {
+			return
+		}
+		if rv1.Kind() == reflect.Map {
+			d.diffMap(path, rv1, rv2)
+			return
+		}
+		fallthrough
+	case reflect.Array:
+		n1, n2 := rv1.Len(), rv2.Len()
+		if n1 != n2 {
+			if n2 == 0 {
+				d.add(path, testMismatchDropped, rv1, rv2)
+				return
+			}
+			d.add(path, testMismatchValue, rv1, rv2)
+		}
+		for i := 0; i < n1 && i < n2; i++ {
+			d.diff(fmt.Sprintf("%s[%d]", path, i), rv1.Index(i), rv2.Index(i))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i1, i2 := rv1.Int(), rv2.Int(); i1 != i2 {
+			d.add(path, testDiffIntCategory(i1, i2), rv1, rv2)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u1, u2 := rv1.Uint(), rv2.Uint(); u1 != u2 {
+			c := testDiffIntCategory(int64(u1), int64(u2))
+			if u1 > math.MaxInt64 && u2 != 0 {
+				c = testMismatchIntegerOverflow
+			}
+			d.add(path, c, rv1, rv2)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f1, f2 := rv1.Float(), rv2.Float(); f1 != f2 && !(math.IsNaN(f1) && math.IsNaN(f2)) {
+			d.add(path, testDiffFloatCategory(f1, f2), rv1, rv2)
+		}
+	default:
+		if !reflect.DeepEqual(testDiffInterface(rv1), testDiffInterface(rv2)) {
+			c := testMismatchValue
+			if rv2.IsZero() {
+				c = testMismatchDropped
+			}
+			d.add(path, c, rv1, rv2)
+		}
+	}
+}
+
+// diffNilEmpty handles nil/empty slices and maps, returning true if there is nothing left to compare.
+func (d *testDiffer) diffNilEmpty(path string, rv1, rv2 reflect.Value) bool {
+	if rv1.Len() != 0 || rv2.Len() != 0 {
+		return false
+	}
+	if rv1.IsNil() != rv2.IsNil() && !d.nilEmptyEqual {
+		d.add(path, testMismatchNilVsEmpty, rv1, rv2)
+	}
+	return true
+}
+
+func (d *testDiffer) diffMap(path string, rv1, rv2 reflect.Value) {
+	if rv2.Len() == 0 {
+		d.add(path, testMismatchDropped, rv1, rv2)
+		return
+	}
+	keys := rv1.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	for _, k := range keys {
+		kpath := fmt.Sprintf("%s[%#v]", path, testDiffInterface(k))
+		d.diff(kpath, rv1.MapIndex(k), rv2.MapIndex(k))
+	}
+	for _, k := range rv2.MapKeys() {
+		if !rv1.MapIndex(k).IsValid() {
+			kpath := fmt.Sprintf("%s[%#v]", path, testDiffInterface(k))
+			d.add(kpath, testMismatchValue, reflect.Value{}, rv2.MapIndex(k))
+		}
+	}
+}
+
+func testDiffInterface(rv reflect.Value) interface{} {
+	if rv.CanInterface() {
+		return rv.Interface()
+	}
+	return rv.String()
+}
+
+func testDiffIntCategory(i1, i2 int64) testMismatchCategory {
+	if i2 == 0 {
+		return testMismatchDropped
+	}
+	// sign flipped, or value was truncated to a smaller width
+	if (i1 < 0) != (i2 < 0) || i2 == int64(int8(i1)) || i2 == int64(int16(i1)) || i2 == int64(int32(i1)) {
+		return testMismatchIntegerOverflow
+	}
+	return testMismatchValue
+}
+
+func testDiffFloatCategory(f1, f2 float64) testMismatchCategory {
+	if f2 == 0 {
+		return testMismatchDropped
+	}
+	if float32(f1) == float32(f2) || math.Abs(f1-f2) <= 1e-6*math.Max(math.Abs(f1), math.Abs(f2)) {
+		return testMismatchPrecisionLoss
+	}
+	return testMismatchValue
+}
+
+func TestDiffCategories(t *testing.T) {
+	type T struct {
+		S    []int
+		M    map[string]int
+		P    *int
+		F    float64
+		I8   int8
+		U64  uint64
+		Str  string
+		Intf interface{}
+		N    struct{ A []string }
+	}
+	one := 1
+	want := T{S: nil, M: map[string]int{"a": 1, "b": 2}, P: &one, F: 1.0 / 3, I8: -1, U64: math.MaxUint64,
+		Str: "x", Intf: int64(1), N: struct{ A []string }{[]string{"a", "b"}}}
+	got := T{S: []int{}, M: map[string]int{"a": 1}, P: nil, F: float64(float32(1.0 / 3)), I8: 127, U64: 1,
+		Str: "x", Intf: uint64(1), N: struct{ A []string }{[]string{"a", "c"}}}
+	expect := map[string]testMismatchCategory{
+		"T.S":      testMismatchNilVsEmpty,
+		`T.M["b"]`: testMismatchDropped,
+		"T.P":      testMismatchDropped,
+		"T.F":      testMismatchPrecisionLoss,
+		"T.I8":     testMismatchIntegerOverflow,
+		"T.U64":    testMismatchIntegerOverflow,
+		"T.Intf":   testMismatchTypeChange,
+		"T.N.A[1]": testMismatchValue,
+	}
+	v := testDiff(&want, &got, false)
+	if len(v) != len(expect) {
+		t.Errorf("expected %d mismatches, got %v", len(expect), v)
+	}
+	for _, m := range v {
+		if c, ok := expect[m.Path]; !ok || c != m.Category {
+			t.Errorf("%s: expected category: %v, got: %v", m.Path, c, m.Category)
+		}
+	}
+	if v = testDiff(&want, &got, true); len(v) != len(expect)-1 {
+		t.Errorf("expected nil-vs-empty to be ignored, got %v", v)
+	}
+	if v = testDiff(&want, &want, false); len(v) != 0 {
+		t.Errorf("expected no mismatches, got %v", v)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file fills values (e.g. a populated TestStruc) with reproducible random data.
+//
+// The values in values_test.go are literals, so every iteration of a benchmark
+// sees identical data, and branch predictors and caches may flatter some decoders.
+// Randomizing them, from a given seed (see -seed), keeps the benchmarks reproducible
+// while varying strings, numbers, slice lengths and map sizes.
+//
+// The shape of the value is kept:
+//   - nil and empty slices, maps and pointers are left as is
+//   - zero scalars stay zero, and non-zero scalars stay non-zero
+//     (except for elements of slices, and values in new map entries)
+//   - slices of structs (or pointers) and maps with struct values keep their length,
+//     with their elements randomized in place; so the depth of TestStruc is kept.
+//   - slices and maps of scalars get a random length within 50% of their original length
+//
+// Consequently, the capabilities a value requires (see benchCap) are unchanged,
+// provided uint64 values are kept at or below math.MaxInt64 where required.
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testRander struct {
+	r *rand.Rand
+
+	// maxUint64 is the largest uint64 value generated (use math.MaxInt64 for benchmarks).
+	maxUint64 uint64
+}
+
+// testRandomize fills the value pointed to by v with random data from r.
+func testRandomize(v interface{}, r *rand.Rand, maxUint64 uint64) {
+	x := testRander{r: r, maxUint64: maxUint64}
+	x.fill(reflect.ValueOf(v), true)
+}
+
+// fill randomizes rv in place. If keepZero, a zero scalar is left as is,
+// and a non-zero scalar is given a non-zero value.
+func (x *testRander) fill(rv reflect.Value, keepZero bool) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			x.fill(rv.Elem(), true)
+		}
+	case reflect.Struct:
+		for i, n := 0, rv.NumField(); i < n; i++ {
+			if f := rv.Field(i); f.CanSet() {
+				x.fill(f, true)
+			}
+		}
+	case reflect.Array:
+		for i, n := 0, rv.Len(); i < n; i++ {
+			x.fill(rv.Index(i), true)
+		}
+	case reflect.Slice:
+		if rv.Len() == 0 {
+			return
+		}
+		if testRandIsScalar(rv.Type().Elem()) {
+			// use the original elements as templates e.g. for the length of strings
+			n := x.length(rv.Len())
+			s := reflect.MakeSlice(rv.Type(), n, n)
+			for i := 0; i < n; i++ {
+				s.Index(i).Set(rv.Index(i % rv.Len()))
+				x.fill(s.Index(i), false)
+			}
+			rv.Set(s)
+			return
+		}
+		for i, n := 0, rv.Len(); i < n; i++ {
+			x.fill(rv.Index(i), true)
+		}
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return
+		}
+		x.fillMap(rv)
+	default:
+		if keepZero && rv.IsZero() {
+			return
+		}
+		for {
+			x.scalar(rv)
+			if !keepZero || !rv.IsZero() {
+				return
+			}
+		}
+	}
+}
+
+func (x *testRander) fillMap(rv reflect.Value) {
+	mt := rv.Type()
+	if testRandIsScalar(mt.Elem()) && testRandIsScalar(mt.Key()) {
+		// use the original entries as templates e.g. for the length of strings
+		keys := testRandSortedKeys(rv)
+		n := x.length(len(keys))
+		m := reflect.MakeMapWithSize(mt, n)
+		k, v := reflect.New(mt.Key()).Elem(), reflect.New(mt.Elem()).Elem()
+		for i := 0; m.Len() < n && i < n*10; i++ {
+			k.Set(keys[i%len(keys)])
+			v.Set(rv.MapIndex(k))
+			x.scalar(k)
+			x.fill(v, false)
+			m.SetMapIndex(k, v)
+		}
+		rv.Set(m)
+		return
+	}
+	// keep the keys, and randomize the values in place (map values are not addressable)
+	for _, k := range testRandSortedKeys(rv) {
+		v := reflect.New(mt.Elem()).Elem()
+		v.Set(rv.MapIndex(k))
+		x.fill(v, true)
+		rv.SetMapIndex(k, v)
+	}
+}
+
+// length returns a random length within 50% of n (and at least 1).
+func (x *testRander) length(n int) int {
+	n = n/2 + x.r.Intn(n+1)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (x *testRander) scalar(rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		rv.SetBool(x.r.Intn(2) == 0)
+	case reflect.String:
+		rv.SetString(x.string(rv.Len()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rv.SetInt(x.int(rv.Type().Bits()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		rv.SetUint(x.uint(rv.Type().Bits()))
+	case reflect.Float32:
+		rv.SetFloat(float64(float32(x.float(30))))
+	case reflect.Float64:
+		rv.SetFloat(x.float(300))
+	}
+}
+
+// int returns a random integer which fits in bits, whose magnitude
+// is spread across the widths, so variable-length encodings vary too.
+func (x *testRander) int(bits int) int64 {
+	k := x.r.Intn(bits) // bits of magnitude: [0, bits-1]
+	v := x.r.Int63() >> (63 - k)
+	if x.r.Intn(2) == 0 {
+		v = -v - 1
+	}
+	return v
+}
+
+func (x *testRander) uint(bits int) (v uint64) {
+	k := x.r.Intn(bits + 1) // bits of magnitude: [0, bits]
+	for {
+		if v = x.r.Uint64() >> (64 - k); v <= x.maxUint64 {
+			return
+		}
+	}
+}
+
+// float returns a finite float, with exponent (base 10) within +/- exp.
+func (x *testRander) float(exp int) float64 {
+	return x.r.NormFloat64() * math.Pow(10, float64(x.r.Intn(2*exp+1)-exp))
+}
+
+var testRandRunes = [...][2]rune{
+	{0x20, 0x7e},       // ascii printable (including quotes and reverse solidus)
+	{0xa1, 0xff},       // latin-1 supplement
+	{0x3b1, 0x3c9},     // greek
+	{0x4e00, 0x4fff},   // cjk
+	{0x1f600, 0x1f64f}, // emoticons (outside the basic multilingual plane)
+}
+
+// string returns a random valid UTF-8 string, of length (in runes) within 50% of n.
+// It is mostly ascii (like typical data), with some multi-byte runes.
+func (x *testRander) string(n int) string {
+	var sb strings.Builder
+	for i, n := 0, x.length(n); i < n; i++ {
+		j := 0
+		if x.r.Intn(5) == 0 {
+			j = 1 + x.r.Intn(len(testRandRunes)-1)
+		}
+		lo, hi := testRandRunes[j][0], testRandRunes[j][1]
+		sb.WriteRune(lo + rune(x.r.Intn(int(hi-lo+1))))
+	}
+	return sb.String()
+}
+
+// testRandSortedKeys returns the keys of the map in a stable order,
+// so the random values generated do not depend on the map iteration order.
+func testRandSortedKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
+
+func testRandIsScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8 // []byte
+	}
+	return false
+}
+
+func TestRandomize(t *testing.T) {
+	fn := func(seed int64) *TestStruc {
+		v := newTestStruc(1, 2, true, false, true)
+		testRandomize(v, rand.New(rand.NewSource(seed)), math.MaxInt64)
+		return v
+	}
+	v1, v2 := fn(7), fn(7)
+	if d := testDiff(v1, v2, false); len(d) != 0 {
+		t.Fatalf("expected same values for same seed, got: %v", d)
+	}
+	v0 := newTestStruc(1, 2, true, false, true)
+	if d := testDiff(v0, fn(8), false); len(d) == 0 {
+		t.Fatalf("expected random values to differ from the literals")
+	}
+	// shapes are kept
+	if v1.Nmap != nil || v1.Nslice != nil || v1.Nint64 != nil || v1.AI64slice0 == nil || len(v1.AI64slice0) != 0 ||
+		v1.AMSU64E == nil || len(v1.AMSU64E) != 0 || len(v1.Its) != len(v0.Its) || len(v1.Mtsptr) != len(v0.Mtsptr) {
+		t.Fatalf("expected nil/empty values and depth to be kept")
+	}
+	for _, u := range v1.AUi64slice {
+		if u > math.MaxInt64 {
+			t.Fatalf("expected uint64 values <= math.MaxInt64, got %v", u)
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"testing"
 
 	"github.com/mailru/easyjson"
 	"github.com/pquerna/ffjson/ffjson"
@@ -39,10 +38,20 @@ func init() {
 }
 
 func benchXGenPreInit() {
+	var ffjsonSkip string
+	if benchXGenSkipFFJSON {
+		ffjsonSkip = "ffjson: generated code fails to compile; checked 2022-12-26"
+	}
 	benchCheckers = append(benchCheckers,
-		benchChecker{"msgp", fnMsgpEncodeFn, fnMsgpDecodeFn},
-		benchChecker{"easyjson", fnEasyjsonEncodeFn, fnEasyjsonDecodeFn},
-		benchChecker{"ffjson", fnFfjsonEncodeFn, fnFfjsonDecodeFn},
+		benchChecker{name: "msgp", title: "Msgp", format: benchFormatMsgpack, group: benchGroupXGen,
+			encodefn: fnMsgpEncodeFn, decodefn: fnMsgpDecodeFn, owndec: true, // decode its own encoding, as it always has
+			caps: benchCapAll &^ benchCapNilVsEmpty},
+		benchChecker{name: "easyjson", title: "Easyjson", format: benchFormatJson, group: benchGroupXGen,
+			encodefn: fnEasyjsonEncodeFn, decodefn: fnEasyjsonDecodeFn,
+			caps: benchCapAll &^ benchCapNilVsEmpty}, // generated with -omit_empty
+		benchChecker{name: "ffjson", title: "Ffjson", format: benchFormatJson, group: benchGroupXGen,
+			encodefn: fnFfjsonEncodeFn, decodefn: fnFfjsonDecodeFn,
+			caps: benchCapAll, skip: ffjsonSkip},
 	)
 }
 
@@ -113,29 +122,3 @@ func fnMsgpDecodeFn(buf []byte, ts interface{}) (err error) {
 	_, err = ts.(msgp.Unmarshaler).UnmarshalMsg(buf)
 	return
 }
-
-func Benchmark__Msgp_______Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "msgp", benchTs, fnMsgpEncodeFn)
-}
-
-func Benchmark__Msgp_______Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "msgp", benchTs, fnMsgpEncodeFn, fnMsgpDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Easyjson___Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "easyjson", benchTs, fnEasyjsonEncodeFn)
-}
-
-func Benchmark__Easyjson___Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "easyjson", benchTs, fnEasyjsonEncodeFn, fnEasyjsonDecodeFn, fnBenchNewTs)
-}
-
-// MARKER use _ in front of func name to prevent it from running
-
-func _Benchmark__Ffjson_____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "ffjson", benchTs, fnFfjsonEncodeFn)
-}
-
-func _Benchmark__Ffjson_____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "ffjson", benchTs, fnFfjsonEncodeFn, fnFfjsonDecodeFn, fnBenchNewTs)
-}
@@ -8,7 +8,7 @@ package codec
 
 import (
 	"bytes"
-	"testing"
+	"io"
 
 	gcbor "bitbucket.org/bodhisnarkva/cbor/go"
 	"github.com/Sereal/Sereal/Go/sereal"
@@ -16,6 +16,7 @@ import (
 	fxcbor "github.com/fxamacker/cbor/v2"
 	mgobson "github.com/globalsign/mgo/bson"
 	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
 	goccyjson "github.com/goccy/go-json"
 	jsoniter "github.com/json-iterator/go"
 	vmsgpack "github.com/vmihailenco/msgpack/v5"
@@ -47,6 +48,18 @@ var jsonv2Opts = jsonv2.JoinOptions(
 	jsonv2.FormatNilMapAsNull(true),
 )
 
+// jsoniterDet is jsoniter.ConfigDefault, with map keys sorted.
+var jsoniterDet = jsoniter.Config{EscapeHTML: true, SortMapKeys: true}.Froze()
+
+// fxcborDet encodes using the Core Deterministic Encoding Requirements of RFC 8949.
+var fxcborDet = func() fxcbor.EncMode {
+	em, err := fxcbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
 func init() {
 	testPreInitFns = append(testPreInitFns, benchXPreInit)
 	_ = bson.NewDecoder
@@ -54,20 +67,50 @@ func init() {
 
 func benchXPreInit() {
 	benchCheckers = append(benchCheckers,
-		benchChecker{"json-iter", fnJsonIterEncodeFn, fnJsonIterDecodeFn},
-		benchChecker{"goccyjson", fnGoccyJsonEncodeFn, fnGoccyJsonDecodeFn},
-		benchChecker{"jsonv2", fnJsonv2EncodeFn, fnJsonv2DecodeFn},
-		benchChecker{"v-msgpack", fnVMsgpackEncodeFn, fnVMsgpackDecodeFn},
-		benchChecker{"bson", fnBsonEncodeFn, fnBsonDecodeFn},
-		benchChecker{"mgobson", fnMgobsonEncodeFn, fnMgobsonDecodeFn},
-		benchChecker{"fxcbor", fnFxcborEncodeFn, fnFxcborDecodeFn},
-
-		// place codecs with issues at the end, so as not to make results too ugly
+		benchChecker{name: "json-iter", title: "JsonIter", format: benchFormatJson, group: benchGroupX,
+			encodefn: fnJsonIterEncodeFn, decodefn: fnJsonIterDecodeFn, caps: benchCapAll,
+			newenc: fnJsonIterNewEnc, newdec: fnJsonIterNewDec,
+			streamenc: fnJsonIterStreamEnc, streamdec: fnJsonIterStreamDec,
+			detenc: fnJsonIterDetEncodeFn},
+		benchChecker{name: "goccyjson", title: "GoccyJson", format: benchFormatJson, group: benchGroupX,
+			encodefn: fnGoccyJsonEncodeFn, decodefn: fnGoccyJsonDecodeFn, caps: benchCapAll,
+			newenc: fnGoccyJsonNewEnc, streamenc: fnGoccyJsonStreamEnc, streamdec: fnGoccyJsonStreamDec,
+			detenc: fnGoccyJsonEncodeFn}, // map keys are sorted by default
+		benchChecker{name: "jsonv2", title: "JsonV2", format: benchFormatJson, group: benchGroupX,
+			encodefn: fnJsonv2EncodeFn, decodefn: fnJsonv2DecodeFn, caps: benchCapAll,
+			streamenc: fnJsonv2StreamEnc, streamdec: fnJsonv2StreamDec,
+			detenc: fnJsonv2DetEncodeFn},
+		benchChecker{name: "fxcbor", title: "Fxcbor", format: benchFormatCbor, group: benchGroupX,
+			encodefn: fnFxcborEncodeFn, decodefn: fnFxcborDecodeFn, caps: benchCapAll,
+			newenc: fnFxcborNewEnc, streamenc: fnFxcborStreamEnc, streamdec: fnFxcborStreamDec,
+			detenc: fnFxcborDetEncodeFn},
+		benchChecker{name: "bson", title: "Bson", format: benchFormatBson, group: benchGroupX,
+			encodefn: fnBsonEncodeFn, decodefn: fnBsonDecodeFn,
+			caps: benchCapAll &^ benchCapUint64AboveMaxInt64},
+		benchChecker{name: "mgobson", title: "Mgobson", format: benchFormatBson, group: benchGroupX,
+			encodefn: fnMgobsonEncodeFn, decodefn: fnMgobsonDecodeFn,
+			caps: benchCapAll &^ (benchCapUint64AboveMaxInt64 | benchCapNilVsEmpty)},
+		benchChecker{name: "v-msgpack", title: "VMsgpack", format: benchFormatMsgpack, group: benchGroupX,
+			encodefn: fnVMsgpackEncodeFn, decodefn: fnVMsgpackDecodeFn, caps: benchCapAll,
+			newenc: fnVMsgpackNewEnc, newdec: fnVMsgpackNewDec,
+			streamenc: fnVMsgpackStreamEnc, streamdec: fnVMsgpackStreamDec,
+			detenc: fnVMsgpackDetEncodeFn},
+
+		// place codecs with issues at the end, so as not to make results too ugly.
 
 		// this logs fat ugly message, but we log.SetOutput(ioutil.Discard)
-		benchChecker{"gcbor", fnGcborEncodeFn, fnGcborDecodeFn},
-		benchChecker{"xdr", fnXdrEncodeFn, fnXdrDecodeFn},
-		benchChecker{"sereal", fnSerealEncodeFn, fnSerealDecodeFn},
+		benchChecker{name: "gcbor", title: "Gcbor", format: benchFormatCbor, group: benchGroupX,
+			encodefn: fnGcborEncodeFn, decodefn: fnGcborDecodeFn,
+			// scalar-ptrs: panics decoding *bool
+			caps: benchCapAll &^ (benchCapNilInPtrSlice | benchCapNilVsEmpty | benchCapPtrMapValues | benchCapStructPtrSlice |
+				benchCapScalarPtrs)},
+		benchChecker{name: "xdr", title: "Xdr", format: benchFormatXdr, group: benchGroupX,
+			encodefn: fnXdrEncodeFn, decodefn: fnXdrDecodeFn,
+			caps: benchCapAll &^ (benchCapNilInPtrSlice | benchCapNilVsEmpty | benchCapNilPtrs)},
+		benchChecker{name: "sereal", title: "Sereal", format: benchFormatSereal, group: benchGroupX,
+			encodefn: fnSerealEncodeFn, decodefn: fnSerealDecodeFn,
+			caps: benchCapAll &^ (benchCapNonStringMapKeys | benchCapNilInPtrSlice | benchCapNilVsEmpty |
+				benchCapScalarPtrs)},
 	)
 }
 
@@ -87,6 +130,52 @@ func fnVMsgpackDecodeFn(buf []byte, ts interface{}) error {
 	return vmsgpack.Unmarshal(buf, ts)
 }
 
+// fnVMsgpackNewEnc returns an encode function using its own Encoder, reset on each call.
+func fnVMsgpackNewEnc() benchEncFn {
+	e := vmsgpack.NewEncoder(nil)
+	return func(ts interface{}, bsIn []byte) ([]byte, error) {
+		buf := fnBenchmarkByteBuf(bsIn)
+		e.Reset(buf)
+		err := e.Encode(ts)
+		return buf.Bytes(), err
+	}
+}
+
+// fnVMsgpackNewDec returns a decode function using its own Decoder, reset on each call.
+func fnVMsgpackNewDec() benchDecFn {
+	d := vmsgpack.NewDecoder(nil)
+	r := bytes.NewReader(nil)
+	return func(buf []byte, ts interface{}) error {
+		r.Reset(buf)
+		d.Reset(r)
+		return d.Decode(ts)
+	}
+}
+
+func fnVMsgpackStreamEnc(w io.Writer) benchStreamFn {
+	return vmsgpack.NewEncoder(w).Encode
+}
+
+func fnVMsgpackStreamDec(r io.Reader) benchStreamFn {
+	return vmsgpack.NewDecoder(r).Decode
+}
+
+// fnVMsgpackDetEncodeFn encodes as fnVMsgpackEncodeFn does, with map keys sorted.
+// vmsgpack.Marshal cannot sort map keys, so it is done as Marshal does (with a pooled Encoder).
+func fnVMsgpackDetEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
+	if testUseIO() {
+		buf := bytes.NewBuffer(bsIn[:0])
+		err := vmsgpack.NewEncoder(buf).SetSortMapKeys(true).Encode(ts)
+		return buf.Bytes(), err
+	}
+	e := vmsgpack.GetEncoder()
+	defer vmsgpack.PutEncoder(e)
+	var buf bytes.Buffer
+	e.Reset(&buf)
+	err := e.SetSortMapKeys(true).Encode(ts)
+	return buf.Bytes(), err
+}
+
 func fnBsonEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
 	return bson.Marshal(ts)
 }
@@ -119,6 +208,77 @@ func fnJsonIterDecodeFn(buf []byte, ts interface{}) error {
 	return jsoniter.Unmarshal(buf, ts)
 }
 
+// fnJsonIterNewEnc returns an encode function using its own Stream, reset on each call.
+//
+// When not using IO, the returned bytes are only valid until the next call.
+func fnJsonIterNewEnc() benchEncFn {
+	s := jsoniter.NewStream(jsoniter.ConfigDefault, nil, 512)
+	return func(ts interface{}, bsIn []byte) ([]byte, error) {
+		var buf *bytes.Buffer
+		if testUseIO() {
+			buf = fnBenchmarkByteBuf(bsIn)
+			s.Reset(buf)
+		} else {
+			s.Reset(nil)
+		}
+		s.Error = nil
+		s.WriteVal(ts)
+		if buf == nil {
+			return s.Buffer(), s.Error
+		}
+		s.Flush()
+		return buf.Bytes(), s.Error
+	}
+}
+
+// fnJsonIterNewDec returns a decode function using its own Iterator, reset on each call.
+func fnJsonIterNewDec() benchDecFn {
+	if testUseIO() {
+		it := jsoniter.Parse(jsoniter.ConfigDefault, nil, 512)
+		r := bytes.NewReader(nil)
+		return func(buf []byte, ts interface{}) error {
+			r.Reset(buf)
+			it.Reset(r)
+			it.Error = nil
+			it.ReadVal(ts)
+			return it.Error
+		}
+	}
+	it := jsoniter.ParseBytes(jsoniter.ConfigDefault, nil)
+	return func(buf []byte, ts interface{}) error {
+		it.ResetBytes(buf)
+		it.Error = nil
+		it.ReadVal(ts)
+		return it.Error
+	}
+}
+
+func fnJsonIterStreamEnc(w io.Writer) benchStreamFn {
+	return jsoniter.NewEncoder(w).Encode
+}
+
+// fnJsonIterStreamDec returns a stream decode function using a jsoniter Decoder,
+// which does not return io.EOF at the end of the stream, so it checks More first.
+// More also returns false on a read error, which is then reported as io.EOF.
+func fnJsonIterStreamDec(r io.Reader) benchStreamFn {
+	d := jsoniter.NewDecoder(r)
+	return func(ts interface{}) error {
+		if !d.More() {
+			return io.EOF
+		}
+		return d.Decode(ts)
+	}
+}
+
+func fnJsonIterDetEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
+	if testUseIO() {
+		buf := bytes.NewBuffer(bsIn[:0])
+		err := jsoniterDet.NewEncoder(buf).Encode(ts)
+		return buf.Bytes(), err
+	}
+	return jsoniterDet.Marshal(ts)
+}
+
 func fnGoccyJsonEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
 	if testUseIO() {
 		buf := fnBenchmarkByteBuf(bsIn)
@@ -135,6 +295,28 @@ func fnGoccyJsonDecodeFn(buf []byte, ts interface{}) error {
 	return goccyjson.Unmarshal(buf, ts)
 }
 
+// fnGoccyJsonNewEnc returns an encode function using its own Encoder, which has no Reset,
+// so it writes to its own buffer (truncated on each call).
+//
+// The returned bytes are only valid until the next call.
+func fnGoccyJsonNewEnc() benchEncFn {
+	buf := new(bytes.Buffer)
+	e := goccyjson.NewEncoder(buf)
+	return func(ts interface{}, bsIn []byte) ([]byte, error) {
+		buf.Reset()
+		err := e.Encode(ts)
+		return buf.Bytes(), err
+	}
+}
+
+func fnGoccyJsonStreamEnc(w io.Writer) benchStreamFn {
+	return goccyjson.NewEncoder(w).Encode
+}
+
+func fnGoccyJsonStreamDec(r io.Reader) benchStreamFn {
+	return goccyjson.NewDecoder(r).Decode
+}
+
 func fnJsonv2EncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
 	if testUseIO() {
 		buf := fnBenchmarkByteBuf(bsIn)
@@ -151,6 +333,31 @@ func fnJsonv2DecodeFn(buf []byte, ts interface{}) error {
 	return jsonv2.Unmarshal(buf, ts, jsonv2Opts)
 }
 
+// fnJsonv2StreamEnc returns a stream encode function using a jsontext.Encoder,
+// which writes a newline after each top-level value.
+func fnJsonv2StreamEnc(w io.Writer) benchStreamFn {
+	e := jsontext.NewEncoder(w, jsonv2Opts)
+	return func(ts interface{}) error {
+		return jsonv2.MarshalEncode(e, ts, jsonv2Opts)
+	}
+}
+
+func fnJsonv2StreamDec(r io.Reader) benchStreamFn {
+	d := jsontext.NewDecoder(r, jsonv2Opts)
+	return func(ts interface{}) error {
+		return jsonv2.UnmarshalDecode(d, ts, jsonv2Opts)
+	}
+}
+
+func fnJsonv2DetEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
+	if testUseIO() {
+		buf := fnBenchmarkByteBuf(bsIn)
+		err := jsonv2.MarshalWrite(buf, ts, jsonv2Opts, jsonv2.Deterministic(true))
+		return buf.Bytes(), err
+	}
+	return jsonv2.Marshal(ts, jsonv2Opts, jsonv2.Deterministic(true))
+}
+
 func fnFxcborEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
 	if testUseIO() {
 		buf := bytes.NewBuffer(bsIn[:0])
@@ -167,6 +374,37 @@ func fnFxcborDecodeFn(buf []byte, ts interface{}) error {
 	return fxcbor.Unmarshal(buf, ts)
 }
 
+// fnFxcborNewEnc returns an encode function using its own Encoder, which has no Reset,
+// so it writes to its own buffer (truncated on each call).
+//
+// The returned bytes are only valid until the next call.
+func fnFxcborNewEnc() benchEncFn {
+	buf := new(bytes.Buffer)
+	e := fxcbor.NewEncoder(buf)
+	return func(ts interface{}, bsIn []byte) ([]byte, error) {
+		buf.Reset()
+		err := e.Encode(ts)
+		return buf.Bytes(), err
+	}
+}
+
+func fnFxcborStreamEnc(w io.Writer) benchStreamFn {
+	return fxcbor.NewEncoder(w).Encode
+}
+
+func fnFxcborStreamDec(r io.Reader) benchStreamFn {
+	return fxcbor.NewDecoder(r).Decode
+}
+
+func fnFxcborDetEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
+	if testUseIO() {
+		buf := bytes.NewBuffer(bsIn[:0])
+		err := fxcborDet.NewEncoder(buf).Encode(ts)
+		return buf.Bytes(), err
+	}
+	return fxcborDet.Marshal(ts)
+}
+
 func fnXdrEncodeFn(ts interface{}, bsIn []byte) ([]byte, error) {
 	buf := fnBenchmarkByteBuf(bsIn)
 	i, err := xdr.Marshal(buf, ts)
@@ -195,85 +433,3 @@ func fnGcborEncodeFn(ts interface{}, bsIn []byte) (bs []byte, err error) {
 func fnGcborDecodeFn(buf []byte, ts interface{}) error {
 	return gcbor.NewDecoder(bytes.NewReader(buf)).Decode(ts)
 }
-
-func Benchmark__JsonIter___Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "jsoniter", benchTs, fnJsonIterEncodeFn)
-}
-
-func Benchmark__JsonIter___Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "jsoniter", benchTs, fnJsonIterEncodeFn, fnJsonIterDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__GoccyJson__Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "goccyjson", benchTs, fnGoccyJsonEncodeFn)
-}
-
-func Benchmark__GoccyJson__Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "goccyjson", benchTs, fnGoccyJsonEncodeFn, fnGoccyJsonDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__JsonV2_____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "jsonv2", benchTs, fnJsonv2EncodeFn)
-}
-
-func Benchmark__JsonV2_____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "jsonv2", benchTs, fnJsonv2EncodeFn, fnJsonv2DecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Fxcbor_____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "fxcbor", benchTs, fnFxcborEncodeFn)
-}
-
-func Benchmark__Fxcbor_____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "fxcbor", benchTs, fnFxcborEncodeFn, fnFxcborDecodeFn, fnBenchNewTs)
-}
-
-// Place codecs with issues at the bottom, so as not to make results look too ugly.
-
-func Benchmark__Mgobson____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "mgobson", benchTs, fnMgobsonEncodeFn)
-}
-
-func Benchmark__Mgobson____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "mgobson", benchTs, fnMgobsonEncodeFn, fnMgobsonDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Bson_______Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "bson", benchTs, fnBsonEncodeFn)
-}
-
-func Benchmark__Bson_______Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "bson", benchTs, fnBsonEncodeFn, fnBsonDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__VMsgpack___Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "v-msgpack", benchTs, fnVMsgpackEncodeFn)
-}
-
-func Benchmark__VMsgpack___Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "v-msgpack", benchTs, fnVMsgpackEncodeFn, fnVMsgpackDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Gcbor______Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "gcbor", benchTs, fnGcborEncodeFn)
-}
-
-func Benchmark__Gcbor______Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "gcbor", benchTs, fnGcborEncodeFn, fnGcborDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Xdr________Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "xdr", benchTs, fnXdrEncodeFn)
-}
-
-func Benchmark__Xdr________Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "xdr", benchTs, fnXdrEncodeFn, fnXdrDecodeFn, fnBenchNewTs)
-}
-
-func Benchmark__Sereal_____Encode(b *testing.B) {
-	fnBenchmarkEncode(b, "sereal", benchTs, fnSerealEncodeFn)
-}
-
-func Benchmark__Sereal_____Decode(b *testing.B) {
-	fnBenchmarkDecode(b, "sereal", benchTs, fnSerealEncodeFn, fnSerealDecodeFn, fnBenchNewTs)
-}
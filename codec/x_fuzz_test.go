@@ -0,0 +1,505 @@
+//go:build x && !generated
+
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package codec
+
+// This file defines a differential fuzz target, which decodes the same input with
+// codec's JsonHandle, encoding/json and jsonv2 (github.com/go-json-experiment/json),
+// to find exactly where JsonHandle accepts or rejects different input than they do.
+//
+// Each input is decoded into an interface{} and into a TestStruc by each library,
+// and codec is compared against each of the others. It is a disagreement if:
+//   - one accepts the input, while the other rejects it
+//   - both accept it, but the decoded values differ
+//
+// The input is decoded from []byte (not a stream), so trailing data after the value must be rejected.
+// Numbers decoded into an interface{} are compared as float64, as encoding/json and jsonv2
+// decode them as float64 while codec may decode them as int64 or uint64.
+//
+// Disagreements explained by a known difference (see fuzzJsonKnownDiffs) do not fail,
+// so the fuzzer can find new ones. The seeds include an example of each known difference;
+// run with -v to log them.
+//
+// Sample way to run:
+//    go test -tags x -run FuzzDecodeJsonDiff -v
+//    go test -tags x -run XXX -fuzz FuzzDecodeJsonDiff -fuzztime 5m
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	jsonv2 "github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	. "github.com/ugorji/go/codec"
+)
+
+// fuzzJsonDecoder decodes a []byte using a json library.
+type fuzzJsonDecoder struct {
+	name string
+	fn   func(bs []byte, v interface{}) error
+}
+
+func fuzzJsonDecoders() []fuzzJsonDecoder {
+	return []fuzzJsonDecoder{
+		{"json", func(bs []byte, v interface{}) error {
+			d := NewDecoderBytes(bs, testJsonH)
+			if err := d.Decode(v); err != nil {
+				return err
+			}
+			return fuzzJsonTrailing(bs, d.NumBytesRead())
+		}},
+		{"std-json", json.Unmarshal},
+		{"jsonv2", func(bs []byte, v interface{}) error { return jsonv2.Unmarshal(bs, v, jsonv2Opts) }},
+	}
+}
+
+// fuzzJsonTrailing returns an error if anything but whitespace follows the first n bytes of bs.
+// codec decodes one value at a time from a stream, so it does not check this itself.
+func fuzzJsonTrailing(bs []byte, n int) error {
+	for _, c := range bs[n:] {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return fmt.Errorf("invalid character %q after top-level value", c)
+		}
+	}
+	return nil
+}
+
+// fuzzJsonDisagreement is a disagreement between codec and another library, on an input.
+type fuzzJsonDisagreement struct {
+	in       []byte
+	other    string      // name of the other library
+	v        interface{} // value decoded into, by codec
+	err      error       // returned by codec
+	otherErr error       // returned by the other library
+}
+
+// fuzzJsonKnownDiff is a known difference between codec and the other libraries,
+// with example inputs (included in the seeds) and whether it explains a disagreement.
+type fuzzJsonKnownDiff struct {
+	desc     string
+	examples []string
+	match    func(x *fuzzJsonDisagreement) bool
+}
+
+// fuzzJsonKnownDiffs are the known differences. More specific differences are listed first.
+var fuzzJsonKnownDiffs = []fuzzJsonKnownDiff{
+	{
+		"codec accepts malformed json e.g. control characters in strings, invalid escapes or numbers, missing values, " +
+			"trailing commas, object member names which are not strings, or control characters between values",
+		[]string{"\"a\tb\"", `"\ugorj"`, `"\u00"0"`, `01`, `-`, `.`, `1.`, `-.5`, `1e`, `E`,
+			`{"a":}`, `{:}`, `[1,]`, `{0:0}`, `{"a":1,true:2}`, "[1,\"a\"\x10]"},
+		func(x *fuzzJsonDisagreement) bool { return x.err == nil && fuzzJsonMalformed(x.in) != "" },
+	},
+	{
+		"codec skips the values of unknown struct fields without checking that they are well-formed",
+		[]string{`{"":{[]}}`, `{"zz":{"a" 1}}`},
+		func(x *fuzzJsonDisagreement) bool {
+			// the input is malformed, if codec rejects it when decoding all of it (into an interface{})
+			return x.err == nil && reflect.TypeOf(x.v).Elem().Kind() == reflect.Struct &&
+				NewDecoderBytes(x.in, testJsonH).Decode(new(interface{})) != nil
+		},
+	},
+	{
+		"codec rejects escaped UTF-16 surrogates which are not in a pair",
+		[]string{`"\ud800"`, `"\udc00x"`},
+		func(x *fuzzJsonDisagreement) bool { return x.err != nil && fuzzJsonLoneSurrogateRe.Match(x.in) },
+	},
+	{
+		"codec decodes an escaped UTF-16 surrogate which is not the start of a pair as U+FFFD, " +
+			"dropping the next 6 bytes",
+		[]string{`"\ud800000000"`, `["\udbffabcdef",1]`, `"\udc00abcdef"`},
+		func(x *fuzzJsonDisagreement) bool {
+			in := fuzzJsonDropAfterLoneSurrogates(x.in)
+			if x.err != nil || in == nil {
+				return false
+			}
+			// encoding/json decodes the input without those bytes to the same value
+			v := reflect.New(reflect.TypeOf(x.v).Elem()).Interface()
+			return json.Unmarshal(in, v) == nil && len(testDiff(fuzzJsonNormalize(v), fuzzJsonNormalize(x.v), false)) == 0
+		},
+	},
+	{
+		"codec rejects integers less than math.MinInt64 when decoding into an interface{}, instead of decoding them as float64",
+		[]string{`-9223372036854775809`, `{"a":-10000000000000000000}`},
+		func(x *fuzzJsonDisagreement) bool {
+			// codec formats (not wraps) the *strconv.NumError
+			return x.err != nil && strings.Contains(x.err.Error(), `strconv.ParseInt: parsing "-`)
+		},
+	},
+	{
+		"jsonv2 rejects duplicate object member names",
+		[]string{`{"a":1,"a":2}`},
+		func(x *fuzzJsonDisagreement) bool { return errors.Is(x.otherErr, jsontext.ErrDuplicateName) },
+	},
+	{
+		"encoding/json matches object member names to struct fields case-insensitively",
+		[]string{`{"i64":1}`, `{"b":1}`},
+		func(x *fuzzJsonDisagreement) bool {
+			return x.other == "std-json" && fuzzJsonCaseInsensitive(x.in, x.v)
+		},
+	},
+	{
+		"codec decodes json arrays into structs (as struct-to-array)",
+		[]string{`[]`, `[1e-400]`},
+		func(x *fuzzJsonDisagreement) bool {
+			k, t := fuzzJsonTypeError(x.otherErr)
+			return x.err == nil && k == '[' && t.Kind() == reflect.Struct
+		},
+	},
+	{
+		"jsonv2 decodes a []byte only from a base64 string, not from an array of numbers",
+		[]string{`{"Ui8slice":[1,2]}`},
+		func(x *fuzzJsonDisagreement) bool {
+			k, t := fuzzJsonTypeError(x.otherErr)
+			return x.err == nil && k == '[' && t == reflect.TypeOf([]byte(nil))
+		},
+	},
+	{
+		"codec converts between json and Go types e.g. strings or floats into integers, numbers into strings",
+		[]string{`{"I64":"1"}`, `{"I64":1.0}`, `{"S":1}`},
+		func(x *fuzzJsonDisagreement) bool {
+			k, t := fuzzJsonTypeError(x.otherErr)
+			if x.err != nil || t == nil {
+				return false
+			}
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			switch t.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				return k == '"' || k == '0'
+			case reflect.String:
+				return k == '0'
+			}
+			return false
+		},
+	},
+}
+
+var fuzzJsonLoneSurrogateRe = regexp.MustCompile(`\\[uU][dD][89a-fA-F]`)
+
+var (
+	fuzzJsonSurrogateRe    = regexp.MustCompile(`\\[uU][dD][89a-fA-F][0-9a-fA-F]{2}`)
+	fuzzJsonLowSurrogateRe = regexp.MustCompile(`^\\[uU][dD][c-fC-F][0-9a-fA-F]{2}`)
+)
+
+// fuzzJsonDropAfterLoneSurrogates returns the input with each escaped surrogate which is not
+// the start of a pair (a high surrogate followed by an escaped low surrogate) replaced with \ufffd,
+// and the next 6 bytes dropped, or nil if there is none.
+func fuzzJsonDropAfterLoneSurrogates(in []byte) (out []byte) {
+	var found bool
+	for {
+		loc := fuzzJsonSurrogateRe.FindIndex(in)
+		if loc == nil {
+			break
+		}
+		next := in[loc[1]:]
+		high := strings.IndexByte("89abAB", in[loc[0]+3]) >= 0 // \uD800 to \uDBFF
+		if len(next) < 6 || high && fuzzJsonLowSurrogateRe.Match(next) {
+			out, in = append(out, in[:loc[1]]...), next
+			continue
+		}
+		out, in, found = append(append(out, in[:loc[0]]...), `\ufffd`...), next[6:], true
+	}
+	if !found {
+		return nil
+	}
+	return append(out, in...)
+}
+
+// fuzzJsonNumberRe matches a valid json number.
+var fuzzJsonNumberRe = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// fuzzJsonLenientNumberRe matches the malformed numbers which codec accepts:
+// leading zeros, a lone minus sign, a missing integer, fraction or exponent part, or no digits at all
+// (e.g. 01, -, .5, 1., 1e, E).
+var fuzzJsonLenientNumberRe = regexp.MustCompile(`^-?[0-9]*(\.[0-9]*)?([eE][-+]?[0-9]*)?$`)
+
+// fuzzJsonMalformed returns the kind of malformed json at the first syntax error in the input
+// (as found by encoding/json), if it is one which codec is known to accept, else "".
+//
+// The error is classified by the input around its offset (not by its message, which varies across go versions).
+func fuzzJsonMalformed(in []byte) string {
+	var se *json.SyntaxError
+	if !errors.As(json.Unmarshal(in, new(interface{})), &se) {
+		return ""
+	}
+	// i is the index of the invalid byte, or len(in) if the input ended early
+	// (Offset is then not at the end, so check with a json.Decoder which reports io.ErrUnexpectedEOF)
+	i := int(se.Offset) - 1
+	if i < 0 || i > len(in) ||
+		errors.Is(json.NewDecoder(bytes.NewReader(in)).Decode(new(interface{})), io.ErrUnexpectedEOF) {
+		i = len(in)
+	}
+	// all before i is valid (except an invalid escape, which i may be at the end of),
+	// so a quote which is not escaped starts or ends a string
+	inString, escaped, hex := false, false, 0 // hex is the count of bytes left in a \u escape
+	for _, c := range in[:i] {
+		switch {
+		case hex > 0:
+			hex--
+		case escaped:
+			escaped = false
+			if c == 'u' {
+				hex = 4
+			}
+		case c == '\\':
+			escaped = inString
+		case c == '"':
+			inString = !inString
+		}
+	}
+	if i == len(in) && inString {
+		return "" // unterminated string
+	}
+	if inString {
+		if in[i] < 0x20 {
+			return "control character in string"
+		}
+		if j := bytes.LastIndexByte(in[:i+1], '\\'); j >= 0 && i-j <= 5 {
+			return "invalid escape"
+		}
+		return ""
+	}
+	// the number which the invalid byte is in, or follows
+	isNum := func(c byte) bool {
+		return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+	}
+	start, end := i, i
+	for start > 0 && isNum(in[start-1]) {
+		start--
+	}
+	for end < len(in) && isNum(in[end]) {
+		end++
+	}
+	// a number may start with an exponent (e.g. E1), but not in the middle of a word (e.g. the e of true)
+	isWord := start > 0 && (in[start-1] >= 'a' && in[start-1] <= 'z' || in[start-1] >= 'A' && in[start-1] <= 'Z')
+	if tok := in[start:end]; len(tok) != 0 && !isWord && !fuzzJsonNumberRe.Match(tok) &&
+		(tok[0] == '-' || tok[0] == '.' || tok[0] == 'e' || tok[0] == 'E' || tok[0] >= '0' && tok[0] <= '9') {
+		if fuzzJsonLenientNumberRe.Match(tok) {
+			return "malformed number"
+		}
+		return ""
+	}
+	if i == len(in) {
+		return ""
+	}
+	if in[i] < 0x20 {
+		return "control character between values"
+	}
+	prev := byte(0) // the last byte before i, other than whitespace
+	for j := i - 1; j >= 0; j-- {
+		if c := in[j]; c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			prev = c
+			break
+		}
+	}
+	switch {
+	case in[i] == '}' && (prev == ':' || prev == ','), in[i] == ']' && prev == ',':
+		return "missing value or trailing comma"
+	case in[i] == ':' && (prev == '{' || prev == ','):
+		return "missing object member name"
+	case prev == '{' || prev == ',':
+		// a number, true, false or null (not a string) followed by a colon
+		j := i
+		for j < len(in) && (isNum(in[j]) || in[j] >= 'a' && in[j] <= 'z') {
+			j++
+		}
+		tok := in[i:j]
+		for j < len(in) && (in[j] == ' ' || in[j] == '\t' || in[j] == '\r' || in[j] == '\n') {
+			j++
+		}
+		if j < len(in) && in[j] == ':' &&
+			(fuzzJsonNumberRe.Match(tok) || strings.Contains(" true false null ", " "+string(tok)+" ")) {
+			return "object member name which is not a string"
+		}
+	}
+	return ""
+}
+
+func init() {
+	// listed last, as it re-checks the input (with the invalid UTF-8 replaced) against the others
+	fuzzJsonKnownDiffs = append(fuzzJsonKnownDiffs, fuzzJsonKnownDiff{
+		"codec keeps invalid UTF-8 in strings as-is (encoding/json replaces it with U+FFFD, jsonv2 rejects it)",
+		[]string{"\"\xff\"", "{\"\x80\":1}", "[\"a\xffb\",1]"},
+		func(x *fuzzJsonDisagreement) bool {
+			in, ok := fuzzJsonFixStrings(x.in)
+			if !ok {
+				return false
+			}
+			// the libraries must agree (or differ as otherwise known), once it is replaced with U+FFFD
+			decs := fuzzJsonDecoders()
+			for _, d := range decs[1:] {
+				if d.name == x.other {
+					y, _ := fuzzJsonCompare(in, reflect.TypeOf(x.v).Elem(), decs[0], d)
+					return y == nil || fuzzJsonKnownDiffOf(y) != ""
+				}
+			}
+			return false
+		},
+	})
+}
+
+// fuzzJsonFixStrings returns the input with the invalid UTF-8 in its strings replaced with U+FFFD
+// (a byte at a time, as encoding/json does), if it has any, and has none outside strings.
+func fuzzJsonFixStrings(in []byte) (out []byte, ok bool) {
+	inString, escaped := false, false
+	for len(in) != 0 {
+		r, n := utf8.DecodeRune(in)
+		b := in[:n]
+		switch {
+		case r == utf8.RuneError && n == 1:
+			if !inString {
+				return nil, false
+			}
+			b, ok, escaped = []byte(string(utf8.RuneError)), true, false
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = inString
+		case r == '"':
+			inString = !inString
+		}
+		out, in = append(out, b...), in[n:]
+	}
+	return out, ok
+}
+
+// fuzzJsonCompare decodes the input into new values of type t, with codec and the other library,
+// and returns their disagreement (and how they disagree), or nil if they agree.
+func fuzzJsonCompare(in []byte, t reflect.Type, codec, other fuzzJsonDecoder) (x *fuzzJsonDisagreement, diff string) {
+	v, otherV := reflect.New(t).Interface(), reflect.New(t).Interface()
+	err, otherErr := codec.fn(in, v), other.fn(in, otherV)
+	switch {
+	case (err == nil) != (otherErr == nil):
+		diff = fmt.Sprintf("%s: %v, %s: %v", codec.name, err, other.name, otherErr)
+	case err == nil:
+		if d := testDiff(fuzzJsonNormalize(otherV), fuzzJsonNormalize(v), false); len(d) != 0 {
+			diff = "decoded values differ: " + d.Error()
+		}
+	}
+	if diff == "" {
+		return nil, ""
+	}
+	return &fuzzJsonDisagreement{in: in, other: other.name, v: v, err: err, otherErr: otherErr}, diff
+}
+
+// fuzzJsonKnownDiffOf returns the known difference which explains the disagreement, or "" if none does.
+func fuzzJsonKnownDiffOf(x *fuzzJsonDisagreement) string {
+	for _, k := range fuzzJsonKnownDiffs {
+		if k.match(x) {
+			return k.desc
+		}
+	}
+	return ""
+}
+
+// fuzzJsonTypeError returns the json kind and Go type of a type error returned by
+// encoding/json or jsonv2, or zero values if err is not a type error.
+func fuzzJsonTypeError(err error) (kind byte, t reflect.Type) {
+	var te *json.UnmarshalTypeError
+	var se *jsonv2.SemanticError
+	switch {
+	case errors.As(err, &te):
+		kind = map[string]byte{"array": '[', "object": '{', "string": '"', "bool": 't', "null": 'n'}[te.Value]
+		if kind == 0 {
+			kind = '0' // number (the value is e.g. "number" or "number 1.5")
+		}
+		return kind, te.Type
+	case errors.As(err, &se) && se.GoType != nil:
+		return byte(se.JSONKind), se.GoType
+	}
+	return
+}
+
+// fuzzJsonCaseInsensitive returns whether decoding in into a new value of v's type
+// gives a different result if object member names match struct fields case-insensitively.
+func fuzzJsonCaseInsensitive(in []byte, v interface{}) bool {
+	rt := reflect.TypeOf(v).Elem()
+	if rt.Kind() != reflect.Struct {
+		return false
+	}
+	v1, v2 := reflect.New(rt).Interface(), reflect.New(rt).Interface()
+	err1 := jsonv2.Unmarshal(in, v1, jsonv2Opts)
+	err2 := jsonv2.Unmarshal(in, v2, jsonv2Opts, jsonv2.MatchCaseInsensitiveNames(true))
+	return (err1 == nil) != (err2 == nil) || len(testDiff(v1, v2, false)) != 0
+}
+
+func FuzzDecodeJsonDiff(f *testing.F) {
+	for _, v := range []interface{}{
+		benchTs,
+		newTestStrucPlus(testv.Depth, testv.NumRepeatString, true, !testv.SkipIntf, testv.MapStringKeyOnly),
+	} {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			f.Fatalf("error encoding seed %T: %v", v, err)
+		}
+		f.Add(bs)
+	}
+	for _, s := range []string{`null`, `true`, `0`, `-1.5e3`, `"aé𝄞"`, `[1,"a",{}]`, `{"a":{"b":[]}}`} {
+		f.Add([]byte(s))
+	}
+	for _, k := range fuzzJsonKnownDiffs {
+		for _, s := range k.examples {
+			f.Add([]byte(s))
+		}
+	}
+	decs := fuzzJsonDecoders()
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		for _, rt := range [...]reflect.Type{reflect.TypeOf((*interface{})(nil)).Elem(), reflect.TypeOf(TestStruc{})} {
+			for _, d := range decs[1:] {
+				x, diff := fuzzJsonCompare(bs, rt, decs[0], d)
+				if x == nil {
+					continue
+				}
+				if k := fuzzJsonKnownDiffOf(x); k != "" {
+					t.Logf("%s vs %s into %T: %q: known difference: %s", decs[0].name, d.name, x.v, benchInteropTrim(string(bs)), k)
+					continue
+				}
+				t.Fatalf("%s vs %s into %T: %s\ninput: %s", decs[0].name, d.name, x.v,
+					benchInteropTrim(diff), benchInteropTrim(fmt.Sprintf("%q", bs)))
+			}
+		}
+	})
+}
+
+// fuzzJsonNormalize returns v with all numbers in an interface{} converted to float64,
+// and all maps converted to map[string]interface{} (as codec decodes json objects as map[interface{}]interface{}).
+func fuzzJsonNormalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *interface{}:
+		return fuzzJsonNormalize(*x)
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case []interface{}:
+		for i := range x {
+			x[i] = fuzzJsonNormalize(x[i])
+		}
+	case map[string]interface{}:
+		for k := range x {
+			x[k] = fuzzJsonNormalize(x[k])
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			m[fmt.Sprint(k)] = fuzzJsonNormalize(v)
+		}
+		return m
+	}
+	return v
+}
@@ -9,8 +9,11 @@ package codec
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	. "github.com/ugorji/go/codec"
 )
 
 func init() {
@@ -86,69 +89,202 @@ func benchmarkQuickSuite(t *testing.B, name string, fns ...func(t *testing.B)) {
 	t.Run(fmt.Sprintf("%s-bd%d-io.....", name, testv.Depth), benchmarkOneFn(fns))
 }
 
-/*
-z='bench_test.go'
-find . -name "$z" | xargs grep -e '^func Benchmark.*Encode' | \
-    cut -d '(' -f 1 | cut -d ' ' -f 2 | \
-    while read f; do echo "t.Run(\"$f\", $f)"; done &&
-echo &&
-find . -name "$z" | xargs grep -e '^func Benchmark.*Decode' | \
-    cut -d '(' -f 1 | cut -d ' ' -f 2 | \
-    while read f; do echo "t.Run(\"$f\", $f)"; done
-*/
+// benchOptionPreset is a named set of codec options, applied to tbvars.E and tbvars.D
+// (and so to each Handle by testUpdateBasicHandleOptions) by benchmarkOptionsSuite,
+// along with options specific to a Handle, set on each shared Handle after it is re-created.
+type benchOptionPreset struct {
+	name string
+	fn   func(e *EncodeOptions, d *DecodeOptions)
+	hfn  func(h Handle)
+}
 
-func benchmarkCodecGroup(t *testing.B) {
-	benchmarkDivider()
-	t.Run("Benchmark__Msgpack____Encode", Benchmark__Msgpack____Encode)
-	t.Run("Benchmark__Binc_______Encode", Benchmark__Binc_______Encode)
-	t.Run("Benchmark__Simple_____Encode", Benchmark__Simple_____Encode)
-	t.Run("Benchmark__Cbor_______Encode", Benchmark__Cbor_______Encode)
-	t.Run("Benchmark__Json_______Encode", Benchmark__Json_______Encode)
-	benchmarkDivider()
-	t.Run("Benchmark__Msgpack____Decode", Benchmark__Msgpack____Decode)
-	t.Run("Benchmark__Binc_______Decode", Benchmark__Binc_______Decode)
-	t.Run("Benchmark__Simple_____Decode", Benchmark__Simple_____Decode)
-	t.Run("Benchmark__Cbor_______Decode", Benchmark__Cbor_______Decode)
-	t.Run("Benchmark__Json_______Decode", Benchmark__Json_______Decode)
+// benchOptionPresets are the presets which benchmarkOptionsSuite can run, in order.
+// Each is applied on top of the options configured by flags (the none preset).
+var benchOptionPresets = []benchOptionPreset{
+	{name: "none"},
+	{name: "canonical", fn: func(e *EncodeOptions, d *DecodeOptions) { e.Canonical = true }},
+	{name: "struct-to-array", fn: func(e *EncodeOptions, d *DecodeOptions) { e.StructToArray = true }},
+	{name: "recursive-empty-check", fn: func(e *EncodeOptions, d *DecodeOptions) { e.RecursiveEmptyCheck = true }},
+	{name: "intern-string", fn: func(e *EncodeOptions, d *DecodeOptions) { d.InternString = true }},
+	{name: "zero-copy", fn: func(e *EncodeOptions, d *DecodeOptions) { d.ZeroCopy = true }},
+	{name: "max-init-len-64", fn: func(e *EncodeOptions, d *DecodeOptions) { d.MaxInitLen = 64 }},
+	{name: "reset", fn: func(e *EncodeOptions, d *DecodeOptions) {
+		d.MapValueReset, d.InterfaceReset, d.SliceElementReset = true, true, true
+	}},
+	{name: "msgpack-no-fixed-num", hfn: func(h Handle) {
+		if x, ok := h.(*MsgpackHandle); ok {
+			x.NoFixedNum = true
+		}
+	}},
+	{name: "cbor-indefinite-length", hfn: func(h Handle) {
+		if x, ok := h.(*CborHandle); ok {
+			x.IndefiniteLength = true
+		}
+	}},
+	{name: "json-indent", hfn: func(h Handle) {
+		if x, ok := h.(*JsonHandle); ok {
+			x.Indent = 2
+		}
+	}},
+}
+
+// benchOptionPresetsSelect returns the presets named in the comma-separated list (or all of them).
+func benchOptionPresetsSelect(names string) (v []benchOptionPreset, err error) {
+	if names == "" || names == "all" {
+		return benchOptionPresets, nil
+	}
+NAMES:
+	for _, n := range strings.Split(names, ",") {
+		n = strings.TrimSpace(n)
+		for _, p := range benchOptionPresets {
+			if p.name == n {
+				v = append(v, p)
+				continue NAMES
+			}
+		}
+		return nil, fmt.Errorf("unknown option preset: %q", n)
+	}
+	return
 }
 
-func BenchmarkCodecSuite(t *testing.B) { benchmarkSuite(t, benchmarkCodecGroup) }
+// benchmarkOptionsSuite runs fns once for each option preset selected by -bop,
+// labelled by the preset e.g. options-canonical.
+//
+// Only codec checkers should be run, as the presets change what codec encodes
+// e.g. struct-to-array encodes structs as arrays, which other libraries cannot decode.
+func benchmarkOptionsSuite(t *testing.B, fns ...func(t *testing.B)) {
+	presets, err := benchOptionPresetsSelect(testv.BenchmarkOptionPresets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, d := tbvars.E, tbvars.D
+	defer func() {
+		tbvars.E, tbvars.D = e, d
+		testReinit()
+	}()
 
-func benchmarkJsonEncodeGroup(t *testing.B) {
-	t.Run("Benchmark__Json_______Encode", Benchmark__Json_______Encode)
+	f := benchmarkOneFn(fns)
+	var width int
+	for _, p := range presets {
+		width = max(width, len(p.name))
+	}
+	for _, p := range presets {
+		tbvars.E, tbvars.D = e, d
+		if p.fn != nil {
+			p.fn(&tbvars.E, &tbvars.D)
+		}
+		testReinit()
+		// json decodes with the reset options set by benchUpdateHandles: use those of the preset instead,
+		// so json runs from the same baseline as the others (else the reset preset would not change it)
+		testJsonH.MapValueReset, testJsonH.InterfaceReset, testJsonH.SliceElementReset =
+			tbvars.D.MapValueReset, tbvars.D.InterfaceReset, tbvars.D.SliceElementReset
+		if p.hfn != nil {
+			for _, h := range testHandles {
+				p.hfn(h)
+			}
+		}
+		t.Run("options-"+p.name+strings.Repeat(".", width-len(p.name)), f)
+	}
 }
 
-func benchmarkJsonDecodeGroup(t *testing.B) {
-	t.Run("Benchmark__Json_______Decode", Benchmark__Json_______Decode)
+// The groups below are derived from the benchCheckers registry.
+//
+// A benchmark group runs the encode benchmarks, then the decode benchmarks,
+// of all registered checkers matching a filter, in registration order.
+
+func benchmarkEncodeGroup(fn func(*benchChecker) bool) func(*testing.B) {
+	return func(t *testing.B) {
+		benchmarkDivider()
+		for _, bc := range benchCheckersFor(fn) {
+			if bc.runnable() {
+				t.Run(bc.benchName("Encode"), bc.benchEncode)
+			}
+		}
+	}
 }
 
-func benchmarkCborEncodeGroup(t *testing.B) {
-	t.Run("Benchmark__Cbor_______Encode", Benchmark__Cbor_______Encode)
+func benchmarkDecodeGroup(fn func(*benchChecker) bool) func(*testing.B) {
+	return func(t *testing.B) {
+		benchmarkDivider()
+		for _, bc := range benchCheckersFor(fn) {
+			if bc.runnable() {
+				t.Run(bc.benchName("Decode"), bc.benchDecode)
+			}
+		}
+	}
 }
 
-func benchmarkCborDecodeGroup(t *testing.B) {
-	t.Run("Benchmark__Cbor_______Decode", Benchmark__Cbor_______Decode)
+func benchmarkGroup(fn func(*benchChecker) bool) func(*testing.B) {
+	return benchmarkOneFn([]func(*testing.B){benchmarkEncodeGroup(fn), benchmarkDecodeGroup(fn)})
+}
+
+// benchmarkSkipMsg returns a message listing the checkers matching fn
+// which are excluded from the benchmarks, and why.
+func benchmarkSkipMsg(fn func(*benchChecker) bool) string {
+	var sb strings.Builder
+	for _, bc := range benchCheckersFor(fn) {
+		for _, w := range benchWorkloadsSelected {
+			if reason := bc.skipReason(w); reason != "" {
+				fmt.Fprintf(&sb, ">>>> Skipping %s: %s\n", bc.name, reason)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func benchmarkGroupFilter(groups ...benchGroup) func(*benchChecker) bool {
+	return func(bc *benchChecker) bool {
+		for _, g := range groups {
+			if bc.group == g {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func benchmarkFormatFilter(fn func(*benchChecker) bool, formats ...benchFormat) func(*benchChecker) bool {
+	return func(bc *benchChecker) bool {
+		if fn != nil && !fn(bc) {
+			return false
+		}
+		for _, f := range formats {
+			if bc.format == f {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// benchmarkWithWorkloads returns a func which runs fn with the named workloads selected
+// (instead of those selected by -bw).
+func benchmarkWithWorkloads(fn func(*testing.B), names ...string) func(*testing.B) {
+	return func(t *testing.B) {
+		defer func(v []*benchWorkload) { benchWorkloadsSelected = v }(benchWorkloadsSelected)
+		benchWorkloadsSelected = nil
+		for _, n := range names {
+			benchWorkloadsSelected = append(benchWorkloadsSelected, benchWorkloadFind(n))
+		}
+		fn(t)
+	}
+}
+
+func BenchmarkCodecSuite(t *testing.B) {
+	benchmarkSuite(t, benchmarkGroup(benchmarkGroupFilter(benchGroupCodec)))
 }
 
 func BenchmarkCodecQuickSuite(t *testing.B) {
-	benchmarkQuickSuite(t, "cbor", benchmarkCborEncodeGroup)
-	benchmarkQuickSuite(t, "cbor", benchmarkCborDecodeGroup)
-	benchmarkQuickSuite(t, "json", benchmarkJsonEncodeGroup)
-	benchmarkQuickSuite(t, "json", benchmarkJsonDecodeGroup)
-
-	// depths := [...]int{1, 4}
-	// for _, d := range depths {
-	// 	benchmarkQuickSuite(t, d, benchmarkJsonEncodeGroup)
-	// 	benchmarkQuickSuite(t, d, benchmarkJsonDecodeGroup)
-	// }
-
-	// benchmarkQuickSuite(t, 1, benchmarkJsonEncodeGroup)
-	// benchmarkQuickSuite(t, 4, benchmarkJsonEncodeGroup)
-	// benchmarkQuickSuite(t, 1, benchmarkJsonDecodeGroup)
-	// benchmarkQuickSuite(t, 4, benchmarkJsonDecodeGroup)
-
-	// benchmarkQuickSuite(t, 1, benchmarkJsonEncodeGroup, benchmarkJsonDecodeGroup)
-	// benchmarkQuickSuite(t, 4, benchmarkJsonEncodeGroup, benchmarkJsonDecodeGroup)
-	// benchmarkQuickSuite(t, benchmarkJsonEncodeGroup)
-	// benchmarkQuickSuite(t, benchmarkJsonDecodeGroup)
+	cbor := benchmarkFormatFilter(benchmarkGroupFilter(benchGroupCodec), benchFormatCbor)
+	json := benchmarkFormatFilter(benchmarkGroupFilter(benchGroupCodec), benchFormatJson)
+	benchmarkQuickSuite(t, "cbor", benchmarkEncodeGroup(cbor))
+	benchmarkQuickSuite(t, "cbor", benchmarkDecodeGroup(cbor))
+	benchmarkQuickSuite(t, "json", benchmarkEncodeGroup(json))
+	benchmarkQuickSuite(t, "json", benchmarkDecodeGroup(json))
+}
+
+// BenchmarkCodecOptionsSuite shows what each codec option costs, by running the codec
+// benchmarks with each option preset (see benchOptionPresets).
+func BenchmarkCodecOptionsSuite(t *testing.B) {
+	benchmarkOptionsSuite(t, benchmarkGroup(benchmarkGroupFilter(benchGroupCodec)))
 }
@@ -10,28 +10,10 @@ package codec
 
 import "testing"
 
-func benchmarkCodecXGenGroup(t *testing.B) {
-	// MARKER: codecgen no longer supported in codec, so do not run those benchmarks here
+// MARKER: codecgen no longer supported in codec, so we do not run those benchmarks here
 
-	benchmarkDivider()
-	t.Run("Benchmark__Msgpack____Encode", Benchmark__Msgpack____Encode)
-	t.Run("Benchmark__Binc_______Encode", Benchmark__Binc_______Encode)
-	t.Run("Benchmark__Simple_____Encode", Benchmark__Simple_____Encode)
-	t.Run("Benchmark__Cbor_______Encode", Benchmark__Cbor_______Encode)
-	t.Run("Benchmark__Json_______Encode", Benchmark__Json_______Encode)
-	t.Run("Benchmark__Msgp_______Encode", Benchmark__Msgp_______Encode)
-	t.Run("Benchmark__Easyjson___Encode", Benchmark__Easyjson___Encode)
-	// t.Run("Benchmark__Ffjson_____Encode", Benchmark__Ffjson_____Encode)
-
-	benchmarkDivider()
-	t.Run("Benchmark__Msgpack____Decode", Benchmark__Msgpack____Decode)
-	t.Run("Benchmark__Binc_______Decode", Benchmark__Binc_______Decode)
-	t.Run("Benchmark__Simple_____Decode", Benchmark__Simple_____Decode)
-	t.Run("Benchmark__Cbor_______Decode", Benchmark__Cbor_______Decode)
-	t.Run("Benchmark__Json_______Decode", Benchmark__Json_______Decode)
-	t.Run("Benchmark__Msgp_______Decode", Benchmark__Msgp_______Decode)
-	t.Run("Benchmark__Easyjson___Decode", Benchmark__Easyjson___Decode)
-	// t.Run("Benchmark__Ffjson_____Decode", Benchmark__Ffjson_____Decode)
+func BenchmarkCodecXGenSuite(t *testing.B) {
+	fn := benchmarkGroupFilter(benchGroupCodec, benchGroupXGen)
+	print(benchmarkSkipMsg(fn))
+	benchmarkSuite(t, benchmarkGroup(fn))
 }
-
-func BenchmarkCodecXGenSuite(t *testing.B) { benchmarkSuite(t, benchmarkCodecXGenGroup) }
@@ -0,0 +1,194 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+/*
+Package benchfmt parses the output of "go test -bench", as produced by running
+the benchmarks directly or via codec/bench.sh.
+
+Each benchmark line is of the form:
+
+	BenchmarkName-8   	   19969	     60187 ns/op	      24 B/op	       1 allocs/op
+
+The configuration of a run is taken from the lines which precede the benchmarks.
+Currently, that is the build tags, as printed by bench.sh e.g.
+
+	>>>> bench TAGS: 'alltests x codec.safe' SUITE: BenchmarkCodecXSuite
+
+or as a "tags: ..." configuration line.
+*/
+package benchfmt
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result is a single line of benchmark output.
+type Result struct {
+	Config string // configuration of the run e.g. build tags
+	Name   string // full name, without the -N (GOMAXPROCS) suffix
+	Procs  int    // GOMAXPROCS, as gotten from the -N suffix
+	N      int    // number of iterations
+
+	Values map[string]float64 // metrics keyed by unit e.g. ns/op, B/op, allocs/op
+}
+
+// Key is used to align results across runs, by name and configuration.
+func (x *Result) Key() string {
+	var sb strings.Builder
+	sb.WriteString(x.Name)
+	if x.Procs > 0 {
+		sb.WriteByte('-')
+		sb.WriteString(strconv.Itoa(x.Procs))
+	}
+	if x.Config != "" {
+		sb.WriteString(" [")
+		sb.WriteString(x.Config)
+		sb.WriteByte(']')
+	}
+	return sb.String()
+}
+
+var (
+	benchTagsRe  = regexp.MustCompile(`^>>>> bench TAGS: '([^']*)'`)
+	benchProcsRe = regexp.MustCompile(`-([0-9]+)$`)
+)
+
+// Parse reads all the benchmark results from r.
+//
+// Lines which are not benchmark results or configuration are ignored.
+func Parse(r io.Reader) (v []Result, err error) {
+	var config string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if m := benchTagsRe.FindStringSubmatch(line); m != nil {
+			config = normalizeTags(m[1])
+			continue
+		}
+		if s, ok := strings.CutPrefix(line, "tags:"); ok {
+			config = normalizeTags(s)
+			continue
+		}
+		if res, ok := parseLine(line); ok {
+			res.Config = config
+			v = append(v, res)
+		}
+	}
+	err = sc.Err()
+	return
+}
+
+func normalizeTags(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func parseLine(line string) (x Result, ok bool) {
+	if !strings.HasPrefix(line, "Benchmark") {
+		return
+	}
+	f := strings.Fields(line)
+	// name, iterations, and at least one (value, unit) pair
+	if len(f) < 4 || len(f)%2 != 0 {
+		return
+	}
+	n, err := strconv.Atoi(f[1])
+	if err != nil {
+		return
+	}
+	x.Name, x.N = f[0], n
+	if m := benchProcsRe.FindStringSubmatchIndex(x.Name); m != nil {
+		x.Procs, _ = strconv.Atoi(x.Name[m[2]:m[3]])
+		x.Name = x.Name[:m[0]]
+	}
+	x.Values = make(map[string]float64, (len(f)-2)/2)
+	for i := 2; i < len(f); i += 2 {
+		fv, err := strconv.ParseFloat(f[i], 64)
+		if err != nil {
+			return
+		}
+		x.Values[f[i+1]] = fv
+	}
+	ok = true
+	return
+}
+
+// Group is the set of results (e.g. across multiple -count runs)
+// which share the same Key.
+type Group struct {
+	Key     string
+	Results []Result
+}
+
+// Samples returns the values for the unit across all results in the group
+// which reported that unit.
+func (x *Group) Samples(unit string) (v []float64) {
+	for i := range x.Results {
+		if fv, ok := x.Results[i].Values[unit]; ok {
+			v = append(v, fv)
+		}
+	}
+	return
+}
+
+// GroupByKey groups results by their Key,
+// keeping the order in which each key was first seen.
+func GroupByKey(results []Result) (v []*Group) {
+	m := make(map[string]*Group)
+	for _, r := range results {
+		k := r.Key()
+		g := m[k]
+		if g == nil {
+			g = &Group{Key: k}
+			m[k] = g
+			v = append(v, g)
+		}
+		g.Results = append(g.Results, r)
+	}
+	return
+}
+
+// Units returns all the units reported across the results, sorted with
+// ns/op, B/op and allocs/op first.
+func Units(results []Result) (v []string) {
+	seen := make(map[string]bool)
+	for i := range results {
+		for u := range results[i].Values {
+			if !seen[u] {
+				seen[u] = true
+				v = append(v, u)
+			}
+		}
+	}
+	sort.Slice(v, func(i, j int) bool {
+		oi, oj := unitOrder(v[i]), unitOrder(v[j])
+		if oi != oj {
+			return oi < oj
+		}
+		return v[i] < v[j]
+	})
+	return
+}
+
+func unitOrder(u string) int {
+	switch u {
+	case "ns/op":
+		return 0
+	case "B/op":
+		return 1
+	case "allocs/op":
+		return 2
+	}
+	return 3
+}
+
+// HigherIsBetter returns true if a larger value for the unit is an improvement
+// e.g. for throughput units like MB/s.
+func HigherIsBetter(unit string) bool {
+	return strings.HasSuffix(unit, "/s")
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package benchfmt
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testBenchOutput = `goos: linux
+>>>> bench TAGS: 'alltests x ' SUITE: BenchmarkCodecXSuite
+
+BenchmarkCodecXSuite/use-bytes......./Benchmark__Msgpack____Encode-8         	   19969	     60187 ns/op	      24 B/op	       1 allocs/op
+BenchmarkCodecXSuite/use-bytes......./Benchmark__Msgpack____Encode-8         	   19000	     60000 ns/op	      24 B/op	       1 allocs/op
+--- SKIP: BenchmarkCodecXSuite/use-bytes......./Benchmark__Xdr_______Encode
+>>>> bench TAGS: 'alltests x codec.safe' SUITE: BenchmarkCodecXSuite
+BenchmarkCodecXSuite/use-bytes......./Benchmark__Msgpack____Encode-8         	   12834	     94111 ns/op	   10048 B/op	     375 allocs/op
+Benchmark__Encode/Json 	       1	    237245 ns/op
+PASS
+`
+
+func TestParse(t *testing.T) {
+	v, err := Parse(strings.NewReader(testBenchOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(v))
+	}
+	r := v[0]
+	if r.Name != "BenchmarkCodecXSuite/use-bytes......./Benchmark__Msgpack____Encode" || r.Procs != 8 ||
+		r.N != 19969 || r.Config != "alltests x" {
+		t.Fatalf("unexpected result: %#v", r)
+	}
+	if r.Values["ns/op"] != 60187 || r.Values["B/op"] != 24 || r.Values["allocs/op"] != 1 {
+		t.Fatalf("unexpected values: %v", r.Values)
+	}
+	if v[3].Name != "Benchmark__Encode/Json" || v[3].Procs != 0 {
+		t.Fatalf("unexpected result: %#v", v[3])
+	}
+	g := GroupByKey(v)
+	if len(g) != 3 || len(g[0].Results) != 2 || g[1].Key == g[0].Key {
+		t.Fatalf("unexpected grouping: %d groups", len(g))
+	}
+	if s := g[0].Samples("ns/op"); len(s) != 2 || Mean(s) != 60093.5 {
+		t.Fatalf("unexpected samples: %v", s)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	for _, tc := range []struct {
+		x, y []float64
+		p    float64
+	}{
+		{[]float64{1, 2, 3, 4}, []float64{5, 6, 7, 8}, 2.0 / 70},      // exact: most extreme ordering
+		{[]float64{5, 6, 7, 8}, []float64{1, 2, 3, 4}, 2.0 / 70},      // symmetric
+		{[]float64{1, 3, 5, 7}, []float64{2, 4, 6, 8}, 0.68571},       // interleaved
+		{[]float64{1, 1, 1, 1}, []float64{1, 1, 1, 1}, 1},             // all ties
+		{[]float64{1}, nil, 1},                                        // empty
+		{[]float64{1, 2, 3, 4, 4}, []float64{5, 6, 6, 7, 8}, 0.01167}, // ties: normal approximation
+	} {
+		if p := MannWhitneyU(tc.x, tc.y); math.Abs(p-tc.p) > 1e-4 {
+			t.Errorf("MannWhitneyU(%v, %v): expected p=%.5f, got %.5f", tc.x, tc.y, tc.p, p)
+		}
+	}
+}
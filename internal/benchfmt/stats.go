@@ -0,0 +1,145 @@
+// Copyright (c) 2012-2020 Ugorji Nwoke. All rights reserved.
+// Use of this source code is governed by a MIT license found in the LICENSE file.
+
+package benchfmt
+
+import (
+	"math"
+	"sort"
+)
+
+// Mean returns the arithmetic mean of v.
+func Mean(v []float64) (m float64) {
+	if len(v) == 0 {
+		return math.NaN()
+	}
+	for _, f := range v {
+		m += f
+	}
+	return m / float64(len(v))
+}
+
+// StdDev returns the sample standard deviation of v.
+func StdDev(v []float64) float64 {
+	if len(v) < 2 {
+		return 0
+	}
+	m := Mean(v)
+	var s float64
+	for _, f := range v {
+		s += (f - m) * (f - m)
+	}
+	return math.Sqrt(s / float64(len(v)-1))
+}
+
+// mwExactLimit is the max of len(x)+len(y) for which
+// MannWhitneyU computes an exact p-value (if there are no ties).
+const mwExactLimit = 50
+
+// MannWhitneyU performs a two-sided Mann-Whitney U-test of x and y,
+// returning the p-value i.e. the probability that the samples come from
+// the same distribution.
+//
+// An exact p-value is computed for small samples without ties.
+// Otherwise, we use a normal approximation with tie and continuity corrections.
+// It returns 1 if either sample is empty.
+func MannWhitneyU(x, y []float64) (p float64) {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type sample struct {
+		v  float64
+		xs bool // from x
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// assign ranks, averaging ties, and accumulating the tie correction term
+	var r1, tieSum float64
+	var ties bool
+	for i := 0; i < len(all); {
+		j := i + 1
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // ranks are 1-based: average of i+1 .. j
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieSum += t*t*t - t
+		}
+		for k := i; k < j; k++ {
+			if all[k].xs {
+				r1 += rank
+			}
+		}
+		i = j
+	}
+	u := r1 - float64(n1*(n1+1))/2
+
+	if !ties && n1+n2 <= mwExactLimit {
+		return mwExactP(n1, n2, u)
+	}
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	mu := fn1 * fn2 / 2
+	sigma := math.Sqrt(fn1 * fn2 / 12 * ((n + 1) - tieSum/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := math.Abs(u-mu) - 0.5
+	if z < 0 {
+		z = 0
+	}
+	z /= sigma
+	p = math.Erfc(z / math.Sqrt2)
+	return math.Min(p, 1)
+}
+
+// mwExactP returns the exact two-sided p-value of the U statistic u,
+// for samples of size n1 and n2 (without ties).
+func mwExactP(n1, n2 int, u float64) float64 {
+	// dist[i][j][k] is the number of orderings of i x's and j y's with U == k.
+	// We only keep a 2-d table per i, building up over i.
+	maxU := n1 * n2
+	prev := make([][]float64, n2+1)
+	for j := range prev {
+		prev[j] = make([]float64, maxU+1)
+		prev[j][0] = 1 // i == 0: U is always 0
+	}
+	for i := 1; i <= n1; i++ {
+		cur := make([][]float64, n2+1)
+		for j := range cur {
+			cur[j] = make([]float64, maxU+1)
+			for k := 0; k <= i*j; k++ {
+				// the largest element is either an x (contributing j to U) or a y.
+				if k >= j {
+					cur[j][k] += prev[j][k-j]
+				}
+				if j > 0 {
+					cur[j][k] += cur[j-1][k]
+				}
+			}
+		}
+		prev = cur
+	}
+	counts := prev[n2]
+	var total, lo, hi float64
+	for k, c := range counts {
+		total += c
+		if float64(k) <= u {
+			lo += c
+		}
+		if float64(k) >= u {
+			hi += c
+		}
+	}
+	return math.Min(1, 2*math.Min(lo, hi)/total)
+}